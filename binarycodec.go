@@ -0,0 +1,845 @@
+package rapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBinaryDepth is the maximum nesting depth of values encoded or decoded by binary codecs.
+const maxBinaryDepth = 10000
+
+// binaryWriter writes the data model shared by binary codecs.
+type binaryWriter interface {
+	writeNil()
+	writeBool(b bool)
+	writeInt(i int64)
+	writeUint(u uint64)
+	writeFloat(f float64, bitSize int)
+	writeString(s string)
+	writeBytes(b []byte)
+	writeArrayHeader(n int)
+	writeMapHeader(n int)
+}
+
+// binaryReadFunc reads the next value from r. The value is one of nil, bool, int64, uint64, float64, string,
+// []byte, time.Time, []interface{} and binaryMap. It returns io.EOF only if r has no more data.
+type binaryReadFunc func(r *bufio.Reader) (interface{}, error)
+
+// binaryMap is the decoded map which keeps the order and types of the keys.
+type binaryMap []binaryMapEntry
+
+// binaryMapEntry is the single entry of binaryMap.
+type binaryMapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// binaryEncoder is the Encoder of binary codecs.
+type binaryEncoder struct {
+	w       io.Writer
+	marshal func(v interface{}) ([]byte, error)
+}
+
+// Encode is the implementation of Encoder.
+func (e *binaryEncoder) Encode(v interface{}) error {
+	data, err := e.marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// binaryDecoder is the Decoder of binary codecs.
+type binaryDecoder struct {
+	r    *bufio.Reader
+	read binaryReadFunc
+}
+
+// Decode is the implementation of Decoder.
+func (d *binaryDecoder) Decode(v interface{}) error {
+	src, err := d.read(d.r)
+	if err != nil {
+		return err
+	}
+	return setBinaryTarget(v, src)
+}
+
+// unmarshalBinary decodes the single value in data into v by using the given binaryReadFunc.
+func unmarshalBinary(data []byte, v interface{}, read binaryReadFunc) error {
+	r := bufio.NewReader(bytes.NewReader(data))
+	src, err := read(r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if _, err = r.Peek(1); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return setBinaryTarget(v, src)
+}
+
+// readBinaryN reads n bytes from r without allocating the whole size in advance.
+func readBinaryN(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt32 {
+		return nil, fmt.Errorf("length %d too large", n)
+	}
+	if n <= 64*1024 {
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return data, nil
+	}
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.CopyN(buf, r, int64(n)); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// binaryCapacity limits the initial capacity of decoded arrays and maps by the given length.
+func binaryCapacity(n uint64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonNumberType      = reflect.TypeOf(json.Number(""))
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// encodeBinaryValue encodes the given value into w by following the encoding/json rules.
+// Struct fields are named by "json" tags, time.Time is encoded as RFC 3339 string, and []byte is encoded as binary.
+func encodeBinaryValue(w binaryWriter, v reflect.Value, depth int) (err error) {
+	if depth > maxBinaryDepth {
+		return errors.New("exceeded max depth")
+	}
+	depth++
+
+	if !v.IsValid() {
+		w.writeNil()
+		return nil
+	}
+
+	typ := v.Type()
+
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		w.writeNil()
+		return nil
+	}
+
+	switch typ {
+	case timeType:
+		w.writeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+		return nil
+	case jsonNumberType:
+		s := v.String()
+		if i, e := strconv.ParseInt(s, 10, 64); e == nil {
+			w.writeInt(i)
+			return nil
+		}
+		if u, e := strconv.ParseUint(s, 10, 64); e == nil {
+			w.writeUint(u)
+			return nil
+		}
+		f, e := strconv.ParseFloat(s, 64)
+		if e != nil {
+			return fmt.Errorf("invalid number literal %q", s)
+		}
+		w.writeFloat(f, 64)
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanAddr() &&
+		(reflect.PtrTo(typ).Implements(jsonMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType)) {
+		v = v.Addr()
+		typ = v.Type()
+	}
+	if typ.Implements(jsonMarshalerType) {
+		var data []byte
+		data, err = v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("unable to marshal %s: %w", typ, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var x interface{}
+		if err = dec.Decode(&x); err != nil {
+			return fmt.Errorf("unable to marshal %s: %w", typ, err)
+		}
+		return encodeBinaryValue(w, reflect.ValueOf(x), depth)
+	}
+	if typ.Implements(textMarshalerType) {
+		var data []byte
+		data, err = v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return fmt.Errorf("unable to marshal %s: %w", typ, err)
+		}
+		w.writeString(string(data))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		w.writeBool(v.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.writeInt(v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.writeUint(v.Uint())
+
+	case reflect.Float32:
+		w.writeFloat(v.Float(), 32)
+
+	case reflect.Float64:
+		w.writeFloat(v.Float(), 64)
+
+	case reflect.String:
+		w.writeString(v.String())
+
+	case reflect.Ptr, reflect.Interface:
+		return encodeBinaryValue(w, v.Elem(), depth)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		if typ.Elem().Kind() == reflect.Uint8 {
+			w.writeBytes(v.Bytes())
+			return nil
+		}
+		fallthrough
+
+	case reflect.Array:
+		w.writeArrayHeader(v.Len())
+		for i, j := 0, v.Len(); i < j; i++ {
+			if err = encodeBinaryValue(w, v.Index(i), depth); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		type entry struct {
+			Key   string
+			Value reflect.Value
+		}
+		entries := make([]entry, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var key string
+			key, err = binaryMapKey(iter.Key())
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry{Key: key, Value: iter.Value()})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Key < entries[j].Key
+		})
+		w.writeMapHeader(len(entries))
+		for _, e := range entries {
+			w.writeString(e.Key)
+			if err = encodeBinaryValue(w, e.Value, depth); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		fields := binaryStructFields(typ)
+		fieldVals := make([]reflect.Value, len(fields))
+		n := 0
+		for i, field := range fields {
+			fieldVal, ok := binaryFieldByIndex(v, field.Index, false)
+			if !ok || (field.OmitEmpty && isEmptyJSONValue(fieldVal)) {
+				continue
+			}
+			fieldVals[i] = fieldVal
+			n++
+		}
+		w.writeMapHeader(n)
+		for i, field := range fields {
+			if !fieldVals[i].IsValid() {
+				continue
+			}
+			w.writeString(field.Name)
+			if err = encodeBinaryValue(w, fieldVals[i], depth); err != nil {
+				return fmt.Errorf("field %q: %w", field.Name, err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+
+	return nil
+}
+
+// binaryMapKey returns the string of the given map key by following the encoding/json rules.
+func binaryMapKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if tm, ok := key.Interface().(encoding.TextMarshaler); ok {
+		if key.Kind() == reflect.Ptr && key.IsNil() {
+			return "", nil
+		}
+		data, err := tm.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("unable to marshal map key: %w", err)
+		}
+		return string(data), nil
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", key.Type())
+}
+
+// isEmptyJSONValue reports whether the given value is empty by following the encoding/json omitempty rules.
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
+
+// binaryField is the struct field encoded by binary codecs.
+type binaryField struct {
+	Name      string
+	Index     []int
+	OmitEmpty bool
+}
+
+var binaryStructFieldsCache sync.Map
+
+// binaryStructFields returns the fields of the given struct type by following the encoding/json rules.
+// The fields of embedded structs without "json" tag names are promoted.
+func binaryStructFields(typ reflect.Type) []binaryField {
+	if v, ok := binaryStructFieldsCache.Load(typ); ok {
+		return v.([]binaryField)
+	}
+
+	var fields []binaryField
+	names := make(map[string]struct{})
+	visited := map[reflect.Type]struct{}{typ: {}}
+	type level struct {
+		Type  reflect.Type
+		Index []int
+	}
+	current := []level{{Type: typ}}
+	for len(current) > 0 {
+		var next []level
+		var levelFields []binaryField
+		for _, l := range current {
+			for i, j := 0, l.Type.NumField(); i < j; i++ {
+				sf := l.Type.Field(i)
+				index := make([]int, len(l.Index)+1)
+				copy(index, l.Index)
+				index[len(l.Index)] = i
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					if !sf.IsExported() && t.Kind() != reflect.Struct {
+						continue
+					}
+					if tagName := strings.SplitN(sf.Tag.Get("json"), ",", 2)[0]; tagName == "" && t.Kind() == reflect.Struct {
+						if _, ok := visited[t]; !ok {
+							visited[t] = struct{}{}
+							next = append(next, level{Type: t, Index: index})
+						}
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				name, omitempty := parseJSONField(sf)
+				if name == "" {
+					continue
+				}
+				if _, ok := names[name]; ok {
+					continue
+				}
+				levelFields = append(levelFields, binaryField{Name: name, Index: index, OmitEmpty: omitempty})
+			}
+		}
+		for _, field := range levelFields {
+			names[field.Name] = struct{}{}
+		}
+		fields = append(fields, levelFields...)
+		current = next
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i].Index, fields[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	binaryStructFieldsCache.Store(typ, fields)
+	return fields
+}
+
+// binaryFieldByIndex returns the nested field of v by the given index.
+// If alloc is true, nil embedded struct pointers are allocated. Otherwise, it returns false on nil pointers.
+func binaryFieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// setBinaryTarget sets the decoded value into the given target which must be a non-nil pointer.
+func setBinaryTarget(target interface{}, src interface{}) error {
+	val := reflect.ValueOf(target)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("target must be non-nil pointer")
+	}
+	return setBinaryValue(val.Elem(), src)
+}
+
+// setBinaryValue sets the decoded value into dst by following the encoding/json rules.
+func setBinaryValue(dst reflect.Value, src interface{}) (err error) {
+	typ := dst.Type()
+
+	if src == nil {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(typ))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(typ.Elem()))
+		}
+		return setBinaryValue(dst.Elem(), src)
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("cannot decode %s into %s", binaryTypeName(src), typ)
+	}
+
+	if typ == timeType {
+		switch s := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(s))
+			return nil
+		case string:
+			var t time.Time
+			if err = t.UnmarshalText([]byte(s)); err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return mismatch()
+	}
+
+	if dst.CanAddr() {
+		ptrTyp := reflect.PtrTo(typ)
+		if ptrTyp.Implements(jsonUnmarshalerType) {
+			var v interface{}
+			v, err = binaryToInterface(src, true)
+			if err != nil {
+				return err
+			}
+			var data []byte
+			data, err = json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("unable to convert %s to json: %w", binaryTypeName(src), err)
+			}
+			return dst.Addr().Interface().(json.Unmarshaler).UnmarshalJSON(data)
+		}
+		if ptrTyp.Implements(textUnmarshalerType) {
+			if s, ok := src.(string); ok {
+				return dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+			}
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if typ.NumMethod() > 0 {
+			return mismatch()
+		}
+		var v interface{}
+		v, err = binaryToInterface(src, false)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(v))
+
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch s := src.(type) {
+		case int64:
+			i = s
+		case uint64:
+			if s > math.MaxInt64 {
+				return fmt.Errorf("value %d overflows %s", s, typ)
+			}
+			i = int64(s)
+		case float64:
+			if s != math.Trunc(s) || s < math.MinInt64 || s >= math.MaxInt64 {
+				return fmt.Errorf("value %v cannot be decoded into %s", s, typ)
+			}
+			i = int64(s)
+		default:
+			return mismatch()
+		}
+		if dst.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", i, typ)
+		}
+		dst.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch s := src.(type) {
+		case int64:
+			if s < 0 {
+				return fmt.Errorf("value %d overflows %s", s, typ)
+			}
+			u = uint64(s)
+		case uint64:
+			u = s
+		case float64:
+			if s != math.Trunc(s) || s < 0 || s >= math.MaxUint64 {
+				return fmt.Errorf("value %v cannot be decoded into %s", s, typ)
+			}
+			u = uint64(s)
+		default:
+			return mismatch()
+		}
+		if dst.OverflowUint(u) {
+			return fmt.Errorf("value %d overflows %s", u, typ)
+		}
+		dst.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch s := src.(type) {
+		case int64:
+			f = float64(s)
+		case uint64:
+			f = float64(s)
+		case float64:
+			f = s
+		default:
+			return mismatch()
+		}
+		if dst.OverflowFloat(f) {
+			return fmt.Errorf("value %v overflows %s", f, typ)
+		}
+		dst.SetFloat(f)
+
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetString(s)
+
+	case reflect.Slice:
+		if b, ok := src.([]byte); ok && typ.Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append(make([]byte, 0, len(b)), b...))
+			return nil
+		}
+		a, ok := src.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		result := reflect.MakeSlice(typ, len(a), len(a))
+		for i := range a {
+			if err = setBinaryValue(result.Index(i), a[i]); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		dst.Set(result)
+
+	case reflect.Array:
+		var a []interface{}
+		switch s := src.(type) {
+		case []interface{}:
+			a = s
+		case []byte:
+			if typ.Elem().Kind() != reflect.Uint8 {
+				return mismatch()
+			}
+			for _, b := range s {
+				a = append(a, int64(b))
+			}
+		default:
+			return mismatch()
+		}
+		for i, j := 0, dst.Len(); i < j; i++ {
+			if i >= len(a) {
+				dst.Index(i).Set(reflect.Zero(typ.Elem()))
+				continue
+			}
+			if err = setBinaryValue(dst.Index(i), a[i]); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+
+	case reflect.Map:
+		m, ok := src.(binaryMap)
+		if !ok {
+			return mismatch()
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(typ, len(m)))
+		}
+		for _, e := range m {
+			key := reflect.New(typ.Key()).Elem()
+			if err = setBinaryMapKey(key, e.Key); err != nil {
+				return err
+			}
+			elem := reflect.New(typ.Elem()).Elem()
+			if err = setBinaryValue(elem, e.Value); err != nil {
+				return fmt.Errorf("key %v: %w", e.Key, err)
+			}
+			dst.SetMapIndex(key, elem)
+		}
+
+	case reflect.Struct:
+		m, ok := src.(binaryMap)
+		if !ok {
+			return mismatch()
+		}
+		fields := binaryStructFields(typ)
+		for _, e := range m {
+			name, ok := e.Key.(string)
+			if !ok {
+				continue
+			}
+			var field *binaryField
+			for i := range fields {
+				if fields[i].Name == name {
+					field = &fields[i]
+					break
+				}
+			}
+			if field == nil {
+				for i := range fields {
+					if strings.EqualFold(fields[i].Name, name) {
+						field = &fields[i]
+						break
+					}
+				}
+			}
+			if field == nil {
+				continue
+			}
+			fieldVal, ok := binaryFieldByIndex(dst, field.Index, true)
+			if !ok || !fieldVal.CanSet() {
+				continue
+			}
+			if err = setBinaryValue(fieldVal, e.Value); err != nil {
+				return fmt.Errorf("field %q: %w", field.Name, err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported type %s", typ)
+	}
+
+	return nil
+}
+
+// setBinaryMapKey sets the decoded map key into dst by following the encoding/json rules.
+func setBinaryMapKey(dst reflect.Value, src interface{}) error {
+	s, isString := src.(string)
+	if !isString {
+		switch k := src.(type) {
+		case int64:
+			s = strconv.FormatInt(k, 10)
+		case uint64:
+			s = strconv.FormatUint(k, 10)
+		default:
+			return fmt.Errorf("cannot decode %s into map key %s", binaryTypeName(src), dst.Type())
+		}
+	}
+	if dst.Kind() == reflect.String {
+		if !isString {
+			return fmt.Errorf("cannot decode %s into map key %s", binaryTypeName(src), dst.Type())
+		}
+		dst.SetString(s)
+		return nil
+	}
+	if tu, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
+	}
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || dst.OverflowInt(i) {
+			return fmt.Errorf("invalid map key %q for %s", s, dst.Type())
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || dst.OverflowUint(u) {
+			return fmt.Errorf("invalid map key %q for %s", s, dst.Type())
+		}
+		dst.SetUint(u)
+		return nil
+	}
+	return fmt.Errorf("unsupported map key type %s", dst.Type())
+}
+
+// binaryToInterface converts the decoded value to the value of an empty interface.
+// Maps are converted to map[string]interface{} if all keys are strings or forJSON is true.
+// Otherwise, it returns an error if any key is an array or a map that can't be the key of map[interface{}]interface{}.
+func binaryToInterface(src interface{}, forJSON bool) (interface{}, error) {
+	switch s := src.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(s))
+		for i := range s {
+			v, err := binaryToInterface(s[i], forJSON)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	case binaryMap:
+		allStrings := true
+		for _, e := range s {
+			if _, ok := e.Key.(string); !ok {
+				allStrings = false
+				break
+			}
+		}
+		if allStrings || forJSON {
+			result := make(map[string]interface{}, len(s))
+			for _, e := range s {
+				v, err := binaryToInterface(e.Value, forJSON)
+				if err != nil {
+					return nil, err
+				}
+				result[fmt.Sprint(e.Key)] = v
+			}
+			return result, nil
+		}
+		result := make(map[interface{}]interface{}, len(s))
+		for _, e := range s {
+			key := e.Key
+			switch k := key.(type) {
+			case []byte:
+				key = string(k)
+			case []interface{}, binaryMap:
+				return nil, fmt.Errorf("invalid map key of %s", binaryTypeName(key))
+			}
+			v, err := binaryToInterface(e.Value, forJSON)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = v
+		}
+		return result, nil
+	}
+	return src, nil
+}
+
+// binaryTypeName returns the name of the decoded value type for error messages.
+func binaryTypeName(src interface{}) string {
+	switch src.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []byte:
+		return "binary"
+	case time.Time:
+		return "time"
+	case []interface{}:
+		return "array"
+	case binaryMap:
+		return "map"
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
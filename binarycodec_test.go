@@ -0,0 +1,200 @@
+package rapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type binaryTestEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+type binaryTestStruct struct {
+	binaryTestEmbedded
+	Bool      bool                   `json:"bool"`
+	Int       int                    `json:"int"`
+	Int8      int8                   `json:"int8"`
+	Uint64    uint64                 `json:"uint64"`
+	Float32   float32                `json:"float32"`
+	Float64   float64                `json:"float64"`
+	String    string                 `json:"string"`
+	Bytes     []byte                 `json:"bytes"`
+	Time      time.Time              `json:"time"`
+	Ptr       *int                   `json:"ptr"`
+	NilPtr    *int                   `json:"nil_ptr"`
+	Slice     []string               `json:"slice"`
+	Array     [2]int                 `json:"array"`
+	Map       map[string]int         `json:"map"`
+	IntMap    map[int]string         `json:"int_map"`
+	Any       interface{}            `json:"any"`
+	AnyMap    map[string]interface{} `json:"any_map"`
+	Raw       json.RawMessage        `json:"raw"`
+	Omitted   string                 `json:"omitted,omitempty"`
+	Ignored   string                 `json:"-"`
+	unexposed string
+}
+
+func newBinaryTestStruct() *binaryTestStruct {
+	ptr := 42
+	return &binaryTestStruct{
+		binaryTestEmbedded: binaryTestEmbedded{Embedded: "embedded"},
+		Bool:               true,
+		Int:                -123456789,
+		Int8:               -8,
+		Uint64:             math.MaxUint64,
+		Float32:            1.5,
+		Float64:            -2.25,
+		String:             strings.Repeat("ü", 300),
+		Bytes:              bytes.Repeat([]byte{0, 1, 2, 255}, 100),
+		Time:               time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC),
+		Ptr:                &ptr,
+		Slice:              []string{"a", "", "c"},
+		Array:              [2]int{-1, 70000},
+		Map:                map[string]int{"a": 1, "b": -70000},
+		IntMap:             map[int]string{-1: "minus", 300: "plus"},
+		Any:                []interface{}{"x", int64(1), 2.5, nil, true},
+		AnyMap:             map[string]interface{}{"nested": map[string]interface{}{"k": "v"}},
+		Raw:                json.RawMessage(`{"raw":[1,2]}`),
+	}
+}
+
+var binaryTestCodecs = []Codec{MessagePackCodec{}, CBORCodec{}}
+
+func TestBinaryCodecRoundTrip(t *testing.T) {
+	for _, codec := range binaryTestCodecs {
+		t.Run(codec.MediaType(), func(t *testing.T) {
+			in := newBinaryTestStruct()
+			data, err := codec.Marshal(in)
+			if err != nil {
+				t.Fatalf("marshal error: %v", err)
+			}
+			out := &binaryTestStruct{}
+			if err = codec.Unmarshal(data, out); err != nil {
+				t.Fatalf("unmarshal error: %v", err)
+			}
+
+			// the decoded value must be the same as the value decoded by encoding/json.
+			jsonData, err := json.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jsonOut := &binaryTestStruct{}
+			if err = json.Unmarshal(jsonData, jsonOut); err != nil {
+				t.Fatal(err)
+			}
+			jsonOut.Any = out.Any
+			if !reflect.DeepEqual(out, jsonOut) {
+				t.Errorf("round trip mismatch:\ngot  %+v\nwant %+v", out, jsonOut)
+			}
+			if want := []interface{}{"x", int64(1), 2.5, nil, true}; !reflect.DeepEqual(out.Any, want) {
+				t.Errorf("any mismatch: got %#v, want %#v", out.Any, want)
+			}
+		})
+	}
+}
+
+func TestBinaryCodecStream(t *testing.T) {
+	for _, codec := range binaryTestCodecs {
+		t.Run(codec.MediaType(), func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			enc := codec.NewEncoder(buf)
+			for i := 0; i < 3; i++ {
+				if err := enc.Encode(map[string]int{"i": i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dec := codec.NewDecoder(buf)
+			for i := 0; i < 3; i++ {
+				var m map[string]int
+				if err := dec.Decode(&m); err != nil {
+					t.Fatal(err)
+				}
+				if m["i"] != i {
+					t.Errorf("got %d, want %d", m["i"], i)
+				}
+			}
+		})
+	}
+}
+
+func TestBinaryCodecMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		data  []byte
+	}{
+		{"cbor array key", CBORCodec{}, []byte{0xa1, 0x61, 0x76, 0xa1, 0x80, 0x00}},
+		{"cbor map key", CBORCodec{}, []byte{0xa1, 0x61, 0x76, 0xa1, 0xa0, 0x00}},
+		{"cbor truncated", CBORCodec{}, []byte{0xa1, 0x61}},
+		{"cbor trailing data", CBORCodec{}, []byte{0x00, 0x00}},
+		{"cbor huge length", CBORCodec{}, []byte{0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"cbor unexpected break", CBORCodec{}, []byte{0xff}},
+		{"cbor invalid info", CBORCodec{}, []byte{0x1c}},
+		{"cbor unterminated indefinite", CBORCodec{}, []byte{0x9f, 0x01}},
+		{"msgpack array key", MessagePackCodec{}, []byte{0x81, 0xa1, 0x76, 0x81, 0x90, 0x00}},
+		{"msgpack map key", MessagePackCodec{}, []byte{0x81, 0xa1, 0x76, 0x81, 0x80, 0x00}},
+		{"msgpack truncated", MessagePackCodec{}, []byte{0x81, 0xa1}},
+		{"msgpack trailing data", MessagePackCodec{}, []byte{0x00, 0x00}},
+		{"msgpack huge length", MessagePackCodec{}, []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{"msgpack invalid format", MessagePackCodec{}, []byte{0xc1}},
+		{"msgpack invalid timestamp", MessagePackCodec{}, []byte{0xd4, 0xff, 0x00}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out struct {
+				V interface{} `json:"v"`
+			}
+			if err := test.codec.Unmarshal(test.data, &out); err == nil {
+				t.Errorf("expected error, got %#v", out)
+			}
+		})
+	}
+}
+
+func TestBinaryCodecDepth(t *testing.T) {
+	for _, codec := range binaryTestCodecs {
+		t.Run(codec.MediaType(), func(t *testing.T) {
+			// single element arrays have the same first byte on both codecs.
+			data := bytes.Repeat([]byte{0x91}, maxBinaryDepth+10)
+			if _, ok := codec.(CBORCodec); ok {
+				data = bytes.Repeat([]byte{0x81}, maxBinaryDepth+10)
+			}
+			data = append(data, 0x00)
+			var out interface{}
+			if err := codec.Unmarshal(data, &out); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestBinaryCodecRandomInput(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, codec := range binaryTestCodecs {
+		t.Run(codec.MediaType(), func(t *testing.T) {
+			for i := 0; i < 20000; i++ {
+				data := make([]byte, 1+rnd.Intn(32))
+				rnd.Read(data)
+				func() {
+					defer func() {
+						if v := recover(); v != nil {
+							t.Fatalf("panic on input % x: %v", data, v)
+						}
+					}()
+					var out interface{}
+					_ = codec.Unmarshal(data, &out)
+					var outStruct binaryTestStruct
+					_ = codec.Unmarshal(data, &outStruct)
+					var outMap map[string]interface{}
+					_ = codec.Unmarshal(data, &outMap)
+				}()
+			}
+		})
+	}
+}
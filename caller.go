@@ -1,6 +1,7 @@
 package rapi
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -15,7 +16,7 @@ import (
 	"strings"
 )
 
-// Caller is the HTTP requester to do JSON requests with the given method to the given endpoint.
+// Caller is the HTTP requester to do JSON or other codec encoded requests with the given method to the given endpoint.
 // The method and endpoint are given from Factory.
 type Caller struct {
 	options *callOptions
@@ -30,7 +31,81 @@ func (c *Caller) Call(ctx context.Context, in interface{}, opts ...CallOption) (
 	options := c.options.Clone()
 	newJoinCallOption(opts...).apply(options)
 
-	req := (&http.Request{
+	return c.newInvokeFunc(options, c.do)(ctx, c.newCallRequest(in, options))
+}
+
+// CallStream does the HTTP request like Call, and returns StreamReader to read the items of the NDJSON or
+// Server-Sent Events response. The items are decoded into the type of the Caller's output.
+// Response.Out is the StreamReader while interceptors are called.
+// StreamReader reconnects on connection loss or end of Server-Sent Events by sending Last-Event-ID header, and
+// the maximum response body size is applied to each item.
+func (c *Caller) CallStream(ctx context.Context, in interface{}, opts ...CallOption) (stream *StreamReader, err error) {
+	options := c.options.Clone()
+	newJoinCallOption(opts...).apply(options)
+
+	invoke := c.newInvokeFunc(options, c.doStream)
+	newCallRequest := func(lastEventID string) *CallRequest {
+		callReq := c.newCallRequest(in, options)
+		if callReq.Header.Get("Accept") == "" {
+			callReq.Header.Set("Accept", StreamNDJSON.MediaType()+", "+StreamSSE.MediaType())
+		}
+		if lastEventID != "" {
+			callReq.Header.Set("Last-Event-ID", lastEventID)
+		}
+		return callReq
+	}
+
+	result, err := invoke(ctx, newCallRequest(""))
+	if err != nil {
+		if result != nil {
+			if s, ok := result.Out.(*StreamReader); ok {
+				_ = s.Close()
+			}
+		}
+		return nil, err
+	}
+	stream, ok := result.Out.(*StreamReader)
+	if !ok {
+		return nil, errors.New("response output is not stream")
+	}
+	stream.ctx = ctx
+	stream.reconnect = func(lastEventID string) (*Response, error) {
+		return invoke(ctx, newCallRequest(lastEventID))
+	}
+	return stream, nil
+}
+
+// CallRaw does the HTTP request like Call, but doesn't decode the response body on success.
+// The decompressed response body is given as Response.Body, and it must be closed.
+// The maximum response body size is applied, and reading beyond the size fails.
+func (c *Caller) CallRaw(ctx context.Context, in interface{}, opts ...CallOption) (result *Response, err error) {
+	options := c.options.Clone()
+	newJoinCallOption(opts...).apply(options)
+
+	return c.newInvokeFunc(options, c.doRaw)(ctx, c.newCallRequest(in, options))
+}
+
+// CallRawTo does the HTTP request like CallRaw, and writes the response body to w.
+func (c *Caller) CallRawTo(ctx context.Context, in interface{}, w io.Writer, opts ...CallOption) (result *Response, err error) {
+	result, err = c.CallRaw(ctx, in, opts...)
+	if err != nil {
+		return result, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(result.Body)
+
+	_, err = io.Copy(w, result.Body)
+	if err != nil {
+		return result, fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// newCallRequest creates a new CallRequest with the given input.
+func (c *Caller) newCallRequest(in interface{}, options *callOptions) *CallRequest {
+	return &CallRequest{
 		Method: c.method,
 		URL: &url.URL{
 			Scheme:   c.url.Scheme,
@@ -39,11 +114,70 @@ func (c *Caller) Call(ctx context.Context, in interface{}, opts ...CallOption) (
 			RawQuery: "",
 		},
 		Header: options.RequestHeader.Clone(),
+		In:     in,
+	}
+}
+
+// newInvokeFunc returns the InvokeFunc that calls the interceptors in order, and then does the HTTP request by
+// using the given function.
+func (c *Caller) newInvokeFunc(options *callOptions, do func(*http.Request, *callOptions) (*Response, error)) InvokeFunc {
+	invoke := func(ctx context.Context, callReq *CallRequest) (*Response, error) {
+		return c.call(ctx, callReq, options, do)
+	}
+	for i := len(options.Interceptors) - 1; i >= 0; i-- {
+		interceptor, next := options.Interceptors[i], invoke
+		if interceptor == nil {
+			continue
+		}
+		invoke = func(ctx context.Context, callReq *CallRequest) (*Response, error) {
+			return interceptor(ctx, callReq, next)
+		}
+	}
+	return invoke
+}
+
+// call does the HTTP request with the given CallRequest by using the given function.
+func (c *Caller) call(ctx context.Context, callReq *CallRequest, options *callOptions,
+	do func(*http.Request, *callOptions) (*Response, error)) (result *Response, err error) {
+	in := callReq.In
+
+	req := (&http.Request{
+		Method: callReq.Method,
+		URL: &url.URL{
+			Scheme:   callReq.URL.Scheme,
+			User:     callReq.URL.User,
+			Host:     callReq.URL.Host,
+			Path:     callReq.URL.Path,
+			RawPath:  callReq.URL.RawPath,
+			RawQuery: callReq.URL.RawQuery,
+		},
+		Header: callReq.Header.Clone(),
 	}).WithContext(ctx)
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", buildAccept(options.Codecs))
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", buildAcceptEncoding(options.Compressors))
+	}
+
+	if upload, ok := in.(*UploadStream); ok && upload != nil {
+		var contentType string
+		req.Body, contentType = newUploadBody(ctx, upload, options.RequestCompressor)
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Del("Content-Length")
+		if options.RequestCompressor != nil {
+			req.Header.Set("Content-Encoding", options.RequestCompressor.Encoding())
+		}
+		return do(req, options)
+	}
 
 	var data []byte
 	if inVal := reflect.ValueOf(in); !options.ForceBody &&
-		(c.method == http.MethodHead || c.method == http.MethodGet || c.method == http.MethodDelete) {
+		(req.Method == http.MethodHead || req.Method == http.MethodGet || req.Method == http.MethodDelete) {
 		if !(in == nil ||
 			inVal.Kind() == reflect.Struct || (inVal.Kind() == reflect.Ptr && inVal.Elem().Kind() == reflect.Struct)) {
 			return nil, errors.New("input must be nil or struct or struct pointer")
@@ -53,18 +187,81 @@ func (c *Caller) Call(ctx context.Context, in interface{}, opts ...CallOption) (
 		if err != nil {
 			return nil, fmt.Errorf("unable to set input to values: %w", err)
 		}
-		req.URL.RawQuery = values.Encode()
+		query := req.URL.Query()
+		for k, v := range values {
+			query[k] = v
+		}
+		req.URL.RawQuery = query.Encode()
+	} else if options.FormRequestBody {
+		if !(in == nil ||
+			inVal.Kind() == reflect.Struct || (inVal.Kind() == reflect.Ptr && inVal.Elem().Kind() == reflect.Struct)) {
+			return nil, errors.New("input must be nil or struct or struct pointer")
+		}
+		var values url.Values
+		var files map[string][]*FormFile
+		values, files, err = structToForm(in)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set input to form: %w", err)
+		}
+		var contentType string
+		if len(files) > 0 {
+			req.Body, contentType = newMultipartFormBody(values, files, options.RequestCompressor)
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Del("Content-Length")
+			if options.RequestCompressor != nil {
+				req.Header.Set("Content-Encoding", options.RequestCompressor.Encoding())
+			}
+			return do(req, options)
+		}
+		data, contentType, err = encodeForm(values, options.MultipartFormBody)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode input: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Content-Length", strconv.FormatInt(int64(len(data)), 10))
 	} else {
-		data, err = json.Marshal(in)
+		codec := options.Codecs[0]
+		data, err = codec.Marshal(in)
 		if err != nil {
 			return nil, fmt.Errorf("unable to encode input: %w", err)
 		}
-		data = append(data, '\n')
-		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Content-Type", contentTypeOf(codec.MediaType()))
+		req.Header.Set("Content-Length", strconv.FormatInt(int64(len(data)), 10))
+	}
+
+	if data != nil && options.RequestCompressor != nil && len(data) >= options.MinRequestCompressionSize {
+		buf := bytes.NewBuffer(nil)
+		var wc io.WriteCloser
+		wc, err = options.RequestCompressor.NewWriter(buf, -1)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create compressor %q: %w", options.RequestCompressor.Encoding(), err)
+		}
+		if _, err = wc.Write(data); err == nil {
+			err = wc.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to compress request body: %w", err)
+		}
+		data = buf.Bytes()
+		req.Header.Set("Content-Encoding", options.RequestCompressor.Encoding())
 		req.Header.Set("Content-Length", strconv.FormatInt(int64(len(data)), 10))
 	}
-	req.Body = io.NopCloser(bytes.NewBuffer(data))
 
+	if options.RetryPolicy == nil {
+		req.Body = io.NopCloser(bytes.NewBuffer(data))
+		return do(req, options)
+	}
+
+	return options.RetryPolicy.do(ctx, req.Method, func() (*Response, error) {
+		attemptReq := req.Clone(ctx)
+		attemptReq.Body = io.NopCloser(bytes.NewBuffer(data))
+		return do(attemptReq, options)
+	})
+}
+
+// do does the given HTTP request and decodes the response.
+func (c *Caller) do(req *http.Request, options *callOptions) (result *Response, err error) {
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, &RequestError{err}
@@ -77,43 +274,50 @@ func (c *Caller) Call(ctx context.Context, in interface{}, opts ...CallOption) (
 		Response: resp,
 	}
 
-	var rd io.Reader = resp.Body
+	drd, err := newResponseReader(resp, options.Compressors)
+	if err != nil {
+		return result, err
+	}
+	defer func(drd io.ReadCloser) {
+		_ = drd.Close()
+	}(drd)
+
+	var rd io.Reader = drd
 	if options.MaxResponseBodySize > 0 {
-		rd = io.LimitReader(resp.Body, options.MaxResponseBodySize)
+		rd = io.LimitReader(rd, options.MaxResponseBodySize)
 	}
+	brd := bufio.NewReader(rd)
+	rd = brd
 
-	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
-		validMediaTypes := []string{"application/json"}
-		if resp.StatusCode != http.StatusOK {
-			validMediaTypes = append(validMediaTypes, "text/plain")
+	if !options.IsSuccess(resp.StatusCode) {
+		return result, newStatusError(result, rd, options)
+	}
+
+	emptyBody := req.Method == http.MethodHead || !bodyAllowedForStatus(resp.StatusCode) || resp.ContentLength == 0
+	if !emptyBody {
+		if _, e := brd.Peek(1); e == io.EOF {
+			emptyBody = true
 		}
+	}
+
+	codec := options.Codecs[0]
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !emptyBody {
 		var mediaType string
-		mediaType, _, err = validateContentType(contentType, validMediaTypes...)
+		mediaType, _, err = validateContentType(contentType, codecMediaTypes(options.Codecs)...)
 		if err != nil {
 			return result, &InvalidContentTypeError{err, contentType}
 		}
-		if mediaType == "text/plain" {
-			data, err = io.ReadAll(io.LimitReader(rd, 1024))
-			if err != nil {
-				return result, fmt.Errorf("unable to read response body: %w", err)
-			}
-			return result, &PlainTextError{errors.New(string(data))}
-		}
+		codec = findCodec(options.Codecs, mediaType)
 	}
 
-	isErr := resp.StatusCode != http.StatusOK && c.options.ErrOut != nil
-
 	outVal := reflect.ValueOf(c.out)
-	if isErr {
-		outVal = reflect.ValueOf(c.options.ErrOut)
-	}
 	copiedOutVal, err := copyReflectValue(outVal)
 	if err != nil {
 		return result, fmt.Errorf("unable to copy output: %w", err)
 	}
 
-	if req.Method != http.MethodHead {
-		err = json.NewDecoder(rd).Decode(copiedOutVal.Interface())
+	if !emptyBody {
+		err = codec.NewDecoder(rd).Decode(copiedOutVal.Interface())
 		if err != nil {
 			return result, fmt.Errorf("unable to decode response body: %w", err)
 		}
@@ -128,13 +332,223 @@ func (c *Caller) Call(ctx context.Context, in interface{}, opts ...CallOption) (
 
 	result.Out = out
 
-	if isErr {
-		return result, out.(error)
+	return result, nil
+}
+
+// doStream does the given HTTP request, and sets StreamReader to read the response body as the output.
+func (c *Caller) doStream(req *http.Request, options *callOptions) (result *Response, err error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &RequestError{err}
+	}
+	closeBody := true
+	defer func(Body io.ReadCloser) {
+		if closeBody {
+			_ = Body.Close()
+		}
+	}(resp.Body)
+
+	result = &Response{
+		Response: resp,
+	}
+
+	drd, err := newResponseReader(resp, options.Compressors)
+	if err != nil {
+		return result, err
+	}
+
+	if !options.IsSuccess(resp.StatusCode) {
+		defer func(drd io.ReadCloser) {
+			_ = drd.Close()
+		}(drd)
+		var rd io.Reader = drd
+		if options.MaxResponseBodySize > 0 {
+			rd = io.LimitReader(rd, options.MaxResponseBodySize)
+		}
+		return result, newStatusError(result, rd, options)
+	}
+
+	stream := &StreamReader{
+		ctx:     req.Context(),
+		options: options,
+		out:     c.out,
+		result:  result,
+		rd:      bufio.NewReader(drd),
+		closers: []io.Closer{drd, resp.Body},
+	}
+
+	if req.Method == http.MethodHead || !bodyAllowedForStatus(resp.StatusCode) {
+		stream.done = true
+	} else {
+		contentType := resp.Header.Get("Content-Type")
+		var mediaType string
+		mediaType, _, err = validateContentType(contentType, StreamNDJSON.MediaType(), StreamSSE.MediaType())
+		if err != nil {
+			_ = drd.Close()
+			return result, &InvalidContentTypeError{err, contentType}
+		}
+		if mediaType == StreamSSE.MediaType() {
+			stream.format = StreamSSE
+		}
+	}
+
+	closeBody = false
+	result.Out = stream
+
+	return result, nil
+}
+
+// doRaw does the given HTTP request, and sets the decompressed response body to the response without decoding.
+func (c *Caller) doRaw(req *http.Request, options *callOptions) (result *Response, err error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &RequestError{err}
+	}
+	closeBody := true
+	defer func(Body io.ReadCloser) {
+		if closeBody {
+			_ = Body.Close()
+		}
+	}(resp.Body)
+
+	result = &Response{
+		Response: resp,
+	}
+
+	drd, err := newResponseReader(resp, options.Compressors)
+	if err != nil {
+		return result, err
+	}
+
+	var rd io.Reader = drd
+	if !options.IsSuccess(resp.StatusCode) {
+		defer func(drd io.ReadCloser) {
+			_ = drd.Close()
+		}(drd)
+		if options.MaxResponseBodySize > 0 {
+			rd = io.LimitReader(rd, options.MaxResponseBodySize)
+		}
+		return result, newStatusError(result, rd, options)
+	}
+
+	if options.MaxResponseBodySize > 0 {
+		rd = &maxSizeReader{r: rd, n: options.MaxResponseBodySize}
+	}
+	resp.Body = &decompressReader{
+		Reader:  rd,
+		closers: []io.Closer{resp.Body, drd},
 	}
+	closeBody = false
 
 	return result, nil
 }
 
+// newResponseReader returns the reader of the response body decompressed according to Content-Encoding header.
+// Closing the reader closes the decompressors, but not the response body.
+func newResponseReader(resp *http.Response, compressors []Compressor) (io.ReadCloser, error) {
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" || resp.Uncompressed {
+		return io.NopCloser(resp.Body), nil
+	}
+
+	brd := bufio.NewReader(resp.Body)
+	var rd io.ReadCloser = io.NopCloser(brd)
+	if _, e := brd.Peek(1); e != io.EOF {
+		var err error
+		rd, err = newDecompressReader(brd, contentEncoding, compressors)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return rd, nil
+}
+
+// newStatusError reads the response body, and creates a new StatusError wraps the error decoded from the body.
+// It sets the decoded error to result.Out if any.
+func newStatusError(result *Response, rd io.Reader, options *callOptions) error {
+	resp := result.Response
+
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	statusErr := &StatusError{
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       data,
+	}
+	if len(statusErr.body) > maxStatusErrorBodySize {
+		statusErr.body = statusErr.body[:maxStatusErrorBodySize]
+	}
+
+	if len(data) == 0 {
+		return statusErr
+	}
+
+	// errors are always accepted as JSON, because Handler encodes errors as JSON by default.
+	codecs := addCodecs([]Codec{JSONCodec{}}, options.Codecs...)
+	mediaType := options.Codecs[0].MediaType()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err = validateContentType(contentType,
+			append(codecMediaTypes(codecs), "text/plain", "application/problem+json")...)
+		if err != nil {
+			statusErr.error = &InvalidContentTypeError{err, contentType}
+			return statusErr
+		}
+	}
+
+	switch mediaType {
+	case "text/plain":
+		statusErr.error = &PlainTextError{errors.New(string(statusErr.body))}
+
+	case "application/problem+json":
+		problemErr := &ProblemError{}
+		err = json.NewDecoder(bytes.NewBuffer(data)).Decode(&problemErr.ProblemDetails)
+		if err != nil {
+			statusErr.error = fmt.Errorf("unable to decode response body: %w", err)
+			return statusErr
+		}
+		if problemErr.Status == 0 {
+			problemErr.Status = resp.StatusCode
+		}
+		result.Out = problemErr
+		statusErr.error = problemErr
+
+	default:
+		errOutProto := options.ErrOutOf(resp.StatusCode)
+		if errOutProto == nil || resp.Request.Method == http.MethodHead {
+			return statusErr
+		}
+		errOutVal := reflect.ValueOf(errOutProto)
+		var copiedErrOutVal reflect.Value
+		copiedErrOutVal, err = copyReflectValue(errOutVal)
+		if err != nil {
+			return fmt.Errorf("unable to copy error output: %w", err)
+		}
+		err = findCodec(codecs, mediaType).Unmarshal(data, copiedErrOutVal.Interface())
+		if err != nil {
+			statusErr.error = fmt.Errorf("unable to decode response body: %w", err)
+			return statusErr
+		}
+		var errOut interface{}
+		if errOutVal.Kind() == reflect.Ptr {
+			errOut = copiedErrOutVal.Interface()
+		} else {
+			errOut = copiedErrOutVal.Elem().Interface()
+		}
+		result.Out = errOut
+		statusErr.error, _ = errOut.(error)
+	}
+
+	return statusErr
+}
+
 // Factory is Caller factory to create new Caller's.
 type Factory struct {
 	options *callOptions
@@ -3,6 +3,7 @@ package rapi
 import (
 	"net/http"
 	"net/textproto"
+	"time"
 )
 
 // CallOption configures how we set up the http call.
@@ -41,15 +42,34 @@ func (o *joinCallOption) apply(options *callOptions) {
 }
 
 type callOptions struct {
-	RequestHeader       http.Header
-	MaxResponseBodySize int64
-	ErrOut              error
-	ForceBody           bool
+	RequestHeader             http.Header
+	MaxResponseBodySize       int64
+	ErrOut                    error
+	StatusErrOuts             map[int]error
+	StatusClassErrOuts        map[int]error
+	ForceBody                 bool
+	SuccessStatusCodes        []int
+	RetryPolicy               *RetryPolicy
+	Interceptors              []InterceptorFunc
+	Codecs                    []Codec
+	FormRequestBody           bool
+	MultipartFormBody         bool
+	Compressors               []Compressor
+	RequestCompressor         Compressor
+	MinRequestCompressionSize int
+	StreamReconnects          int
+	StreamReconnectDelay      time.Duration
 }
 
 func newCallOptions() (o *callOptions) {
 	return &callOptions{
-		RequestHeader: http.Header{},
+		RequestHeader:        http.Header{},
+		StatusErrOuts:        map[int]error{},
+		StatusClassErrOuts:   map[int]error{},
+		Codecs:               defaultCodecs(),
+		Compressors:          defaultCompressors(),
+		StreamReconnects:     3,
+		StreamReconnectDelay: 3 * time.Second,
 	}
 }
 
@@ -58,14 +78,62 @@ func (o *callOptions) Clone() *callOptions {
 		return nil
 	}
 	result := &callOptions{
-		RequestHeader:       o.RequestHeader.Clone(),
-		MaxResponseBodySize: o.MaxResponseBodySize,
-		ErrOut:              o.ErrOut,
-		ForceBody:           o.ForceBody,
+		RequestHeader:             o.RequestHeader.Clone(),
+		MaxResponseBodySize:       o.MaxResponseBodySize,
+		ErrOut:                    o.ErrOut,
+		StatusErrOuts:             make(map[int]error, len(o.StatusErrOuts)),
+		StatusClassErrOuts:        make(map[int]error, len(o.StatusClassErrOuts)),
+		ForceBody:                 o.ForceBody,
+		SuccessStatusCodes:        o.SuccessStatusCodes,
+		RetryPolicy:               o.RetryPolicy,
+		Interceptors:              make([]InterceptorFunc, len(o.Interceptors)),
+		Codecs:                    make([]Codec, len(o.Codecs)),
+		FormRequestBody:           o.FormRequestBody,
+		MultipartFormBody:         o.MultipartFormBody,
+		Compressors:               make([]Compressor, len(o.Compressors)),
+		RequestCompressor:         o.RequestCompressor,
+		MinRequestCompressionSize: o.MinRequestCompressionSize,
+		StreamReconnects:          o.StreamReconnects,
+		StreamReconnectDelay:      o.StreamReconnectDelay,
+	}
+	copy(result.Interceptors, o.Interceptors)
+	copy(result.Codecs, o.Codecs)
+	copy(result.Compressors, o.Compressors)
+	for k, v := range o.StatusErrOuts {
+		result.StatusErrOuts[k] = v
+	}
+	for k, v := range o.StatusClassErrOuts {
+		result.StatusClassErrOuts[k] = v
 	}
 	return result
 }
 
+// IsSuccess reports whether the given status code is success.
+// All 2xx status codes are success if the success status codes aren't defined.
+func (o *callOptions) IsSuccess(statusCode int) bool {
+	if o.SuccessStatusCodes == nil {
+		return statusCode >= 200 && statusCode <= 299
+	}
+	for _, code := range o.SuccessStatusCodes {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrOutOf returns the error output defined for the given status code.
+// The precedence is exact status code, status class and fallback error output.
+func (o *callOptions) ErrOutOf(statusCode int) error {
+	if errOut, ok := o.StatusErrOuts[statusCode]; ok {
+		return errOut
+	}
+	if errOut, ok := o.StatusClassErrOuts[statusCode/100]; ok {
+		return errOut
+	}
+	return o.ErrOut
+}
+
 // WithRequestHeader returns a CallOption that sets the given http headers to the request headers.
 func WithRequestHeader(headers ...http.Header) CallOption {
 	return newFuncCallOption(func(options *callOptions) {
@@ -92,22 +160,136 @@ func WithAdditionalRequestHeader(headers ...http.Header) CallOption {
 }
 
 // WithMaxResponseBodySize returns a CallOption that limits maximum response body size.
+// The limit is applied to the decompressed response body.
 func WithMaxResponseBodySize(maxResponseBodySize int64) CallOption {
 	return newFuncCallOption(func(options *callOptions) {
 		options.MaxResponseBodySize = maxResponseBodySize
 	})
 }
 
-// WithErrOut returns a CallOption that defines the error output to return as Caller.Call error.
+// WithErrOut returns a CallOption that defines the fallback error output to decode when the response status code is not
+// success.
+// Caller.Call returns *StatusError that wraps the decoded error output.
 func WithErrOut(errOut error) CallOption {
 	return newFuncCallOption(func(options *callOptions) {
 		options.ErrOut = errOut
 	})
 }
 
+// WithStatusErrOut returns a CallOption that defines the error output to decode for the given exact response status codes.
+// It takes precedence over WithStatusClassErrOut and WithErrOut.
+func WithStatusErrOut(errOut error, statusCodes ...int) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		for _, statusCode := range statusCodes {
+			options.StatusErrOuts[statusCode] = errOut
+		}
+	})
+}
+
+// WithStatusClassErrOut returns a CallOption that defines the error output to decode for the given response status
+// classes such as 4 for 4xx and 5 for 5xx. It takes precedence over WithErrOut.
+func WithStatusClassErrOut(errOut error, statusClasses ...int) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		for _, statusClass := range statusClasses {
+			options.StatusClassErrOuts[statusClass] = errOut
+		}
+	})
+}
+
+// WithSuccessStatusCodes returns a CallOption that defines the response status codes to be treated as success.
+// By default, all 2xx status codes are success.
+func WithSuccessStatusCodes(statusCodes ...int) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.SuccessStatusCodes = append([]int{}, statusCodes...)
+	})
+}
+
 // WithForceBody returns a CallOption that forces sending input in the request body for all methods including HEAD and GET.
 func WithForceBody(forceBody bool) CallOption {
 	return newFuncCallOption(func(options *callOptions) {
 		options.ForceBody = forceBody
 	})
 }
+
+// WithRetry returns a CallOption that retries the failed calls according to the given RetryPolicy.
+// Retry-After header is honoured on 429 and 503 up to MaxBackoff, and no retry is done beyond the context deadline.
+// If policy is nil, retries are disabled.
+func WithRetry(policy *RetryPolicy) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.RetryPolicy = policy
+	})
+}
+
+// WithInterceptor returns a CallOption that adds interceptors.
+// Interceptors given from Factory, Caller and Caller.Call are called in order.
+func WithInterceptor(interceptors ...InterceptorFunc) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.Interceptors = append(options.Interceptors, interceptors...)
+	})
+}
+
+// WithCallCodec returns a CallOption that sets the codecs in the order of preference.
+// The first codec encodes request bodies, and Accept header is sent with all codecs unless it's given by the request header.
+// Response bodies are decoded by the codec of Content-Type.
+// By default, only JSONCodec is used. If no codecs are given, the default is restored.
+func WithCallCodec(codecs ...Codec) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.Codecs = addCodecs(nil, codecs...)
+		if len(options.Codecs) == 0 {
+			options.Codecs = defaultCodecs()
+		}
+	})
+}
+
+// WithFormRequestBody returns a CallOption that sends input as the form request body instead of the codec.
+// Input fields are encoded in the same way as query strings, and FormFile fields created by NewFormFile are sent
+// as file parts. The body is multipart/form-data if there are file parts, otherwise application/x-www-form-urlencoded.
+// The file parts are streamed while the request is sent, so the calls with file parts aren't retried.
+func WithFormRequestBody(formRequestBody bool) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.FormRequestBody = formRequestBody
+	})
+}
+
+// WithMultipartFormBody returns a CallOption that sends the form request body always as multipart/form-data.
+// It's effective with WithFormRequestBody.
+func WithMultipartFormBody(multipartFormBody bool) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.MultipartFormBody = multipartFormBody
+	})
+}
+
+// WithCallCompressor returns a CallOption that sets the compressors to decompress responses.
+// Accept-Encoding header is sent with all compressors unless it's given by the request header,
+// and the response body is decompressed according to Content-Encoding header before decoding.
+// By default, GzipCompressor and DeflateCompressor are used. If no compressors are given, the default is restored.
+func WithCallCompressor(compressors ...Compressor) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.Compressors = addCompressors(nil, compressors...)
+		if len(options.Compressors) == 0 {
+			options.Compressors = defaultCompressors()
+		}
+	})
+}
+
+// WithRequestCompression returns a CallOption that compresses request bodies by using the given compressor.
+// Request bodies smaller than minSize aren't compressed, but UploadStream is always compressed on the fly.
+// If compressor is nil, request bodies aren't compressed.
+// By default, request bodies aren't compressed.
+func WithRequestCompression(compressor Compressor, minSize int) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.RequestCompressor = compressor
+		options.MinRequestCompressionSize = minSize
+	})
+}
+
+// WithStreamReconnect returns a CallOption that sets the maximum consecutive reconnection attempts and the delay between
+// them on connection loss or end of Server-Sent Events streams. The attempts are consecutive until an event is received,
+// and the delay is overridden by the retry field of the events.
+// By default, 3 attempts are done with the delay of 3 seconds. If maxAttempts is zero, reconnection is disabled.
+func WithStreamReconnect(maxAttempts int, delay time.Duration) CallOption {
+	return newFuncCallOption(func(options *callOptions) {
+		options.StreamReconnects = maxAttempts
+		options.StreamReconnectDelay = delay
+	})
+}
@@ -0,0 +1,339 @@
+package rapi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// CBORCodec is the Codec of "application/cbor" described in RFC 8949.
+// Values are encoded by following the encoding/json rules such as "json" struct tags and omitempty.
+// time.Time is encoded as RFC 3339 string, and []byte is encoded as byte string.
+// Indefinite-length items and the date/time tags 0 and 1 are also accepted while decoding.
+type CBORCodec struct{}
+
+// MediaType is the implementation of Codec.
+func (CBORCodec) MediaType() string {
+	return "application/cbor"
+}
+
+// Marshal is the implementation of Codec.
+func (CBORCodec) Marshal(v interface{}) ([]byte, error) {
+	w := &cborWriter{}
+	if err := encodeBinaryValue(w, reflect.ValueOf(v), 0); err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	return w.buf, nil
+}
+
+// Unmarshal is the implementation of Codec.
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := unmarshalBinary(data, v, readCBOR); err != nil {
+		return fmt.Errorf("cbor: %w", err)
+	}
+	return nil
+}
+
+// NewEncoder is the implementation of Codec.
+func (c CBORCodec) NewEncoder(w io.Writer) Encoder {
+	return &binaryEncoder{w: w, marshal: c.Marshal}
+}
+
+// NewDecoder is the implementation of Codec.
+func (CBORCodec) NewDecoder(r io.Reader) Decoder {
+	return &binaryDecoder{r: bufio.NewReader(r), read: readCBOR}
+}
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// cborBreak is the "break" stop code of indefinite-length items.
+const cborBreak = 0xff
+
+// cborWriter is the binaryWriter of CBOR.
+type cborWriter struct {
+	buf []byte
+}
+
+func (w *cborWriter) writeHead(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, major|25)
+		w.buf = appendUint16(w.buf, uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = append(w.buf, major|26)
+		w.buf = appendUint32(w.buf, uint32(n))
+	default:
+		w.buf = append(w.buf, major|27)
+		w.buf = appendUint64(w.buf, n)
+	}
+}
+
+func (w *cborWriter) writeNil() {
+	w.buf = append(w.buf, 0xf6)
+}
+
+func (w *cborWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, 0xf5)
+	} else {
+		w.buf = append(w.buf, 0xf4)
+	}
+}
+
+func (w *cborWriter) writeInt(i int64) {
+	if i >= 0 {
+		w.writeHead(cborUint, uint64(i))
+		return
+	}
+	w.writeHead(cborNegInt, uint64(-1-i))
+}
+
+func (w *cborWriter) writeUint(u uint64) {
+	w.writeHead(cborUint, u)
+}
+
+func (w *cborWriter) writeFloat(f float64, bitSize int) {
+	if bitSize == 32 {
+		w.buf = append(w.buf, 0xfa)
+		w.buf = appendUint32(w.buf, math.Float32bits(float32(f)))
+		return
+	}
+	w.buf = append(w.buf, 0xfb)
+	w.buf = appendUint64(w.buf, math.Float64bits(f))
+}
+
+func (w *cborWriter) writeString(s string) {
+	w.writeHead(cborText, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *cborWriter) writeBytes(b []byte) {
+	w.writeHead(cborBytes, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *cborWriter) writeArrayHeader(n int) {
+	w.writeHead(cborArray, uint64(n))
+}
+
+func (w *cborWriter) writeMapHeader(n int) {
+	w.writeHead(cborMap, uint64(n))
+}
+
+// errCBORBreak is returned from readCBORValue when the "break" stop code is read.
+var errCBORBreak = errors.New("unexpected break stop code")
+
+// readCBOR is the binaryReadFunc of CBOR.
+func readCBOR(r *bufio.Reader) (interface{}, error) {
+	return readCBORValue(r, 0)
+}
+
+func readCBORValue(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("exceeded max depth")
+	}
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if c == cborBreak {
+		return nil, errCBORBreak
+	}
+
+	major, info := c>>5, c&0x1f
+	indefinite := info == 31
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		var data []byte
+		data, err = readBinaryN(r, 1<<(info-24))
+		if err != nil {
+			return nil, err
+		}
+		switch len(data) {
+		case 1:
+			n = uint64(data[0])
+		case 2:
+			n = uint64(binary.BigEndian.Uint16(data))
+		case 4:
+			n = uint64(binary.BigEndian.Uint32(data))
+		default:
+			n = binary.BigEndian.Uint64(data)
+		}
+	case indefinite && (major == cborBytes || major == cborText || major == cborArray || major == cborMap):
+	default:
+		return nil, fmt.Errorf("invalid additional information %d of major type %d", info, major)
+	}
+
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("negative integer -1-%d overflows int64", n)
+		}
+		return -1 - int64(n), nil
+
+	case cborBytes, cborText:
+		var data []byte
+		if indefinite {
+			data, err = readCBORChunks(r, major)
+		} else {
+			data, err = readBinaryN(r, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(data), nil
+		}
+		return data, nil
+
+	case cborArray:
+		result := make([]interface{}, 0, binaryCapacity(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			v, err := readCBORValue(r, depth+1)
+			if indefinite && err == errCBORBreak {
+				break
+			}
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			result = append(result, v)
+		}
+		return result, nil
+
+	case cborMap:
+		result := make(binaryMap, 0, binaryCapacity(n))
+		for i := uint64(0); indefinite || i < n; i++ {
+			k, err := readCBORValue(r, depth+1)
+			if indefinite && err == errCBORBreak {
+				break
+			}
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			v, err := readCBORValue(r, depth+1)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			result = append(result, binaryMapEntry{Key: k, Value: v})
+		}
+		return result, nil
+
+	case cborTag:
+		v, err := readCBORValue(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if n == 1 {
+			switch x := v.(type) {
+			case int64:
+				return time.Unix(x, 0).UTC(), nil
+			case uint64:
+				return nil, fmt.Errorf("epoch time %d overflows", x)
+			case float64:
+				sec, frac := math.Modf(x)
+				return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+			}
+			return nil, fmt.Errorf("invalid epoch time of %s", binaryTypeName(v))
+		}
+		return v, nil
+
+	case cborSimple:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			return float16ToFloat64(uint16(n)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(n))), nil
+		case 27:
+			return math.Float64frombits(n), nil
+		}
+		return nil, fmt.Errorf("unsupported simple value %d", n)
+	}
+
+	return nil, fmt.Errorf("invalid major type %d", major)
+}
+
+// readCBORChunks reads the chunks of the indefinite-length byte or text string.
+func readCBORChunks(r *bufio.Reader, major byte) ([]byte, error) {
+	var result []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if c == cborBreak {
+			return result, nil
+		}
+		if c>>5 != major || c&0x1f == 31 {
+			return nil, errors.New("invalid chunk of indefinite-length string")
+		}
+		if err = r.UnreadByte(); err != nil {
+			return nil, err
+		}
+		v, err := readCBORValue(r, 0)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch chunk := v.(type) {
+		case []byte:
+			result = append(result, chunk...)
+		case string:
+			result = append(result, chunk...)
+		}
+	}
+}
+
+// float16ToFloat64 converts the IEEE 754 half-precision float to float64.
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp, frac := int(h>>10)&0x1f, float64(h&0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
@@ -0,0 +1,254 @@
+package rapi
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes and decodes the request and response bodies of the media type.
+type Codec interface {
+	// MediaType returns the media type such as "application/json".
+	MediaType() string
+
+	// Marshal returns the encoding of v.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into v.
+	Unmarshal(data []byte, v interface{}) error
+
+	// NewEncoder returns a new Encoder that writes to w.
+	NewEncoder(w io.Writer) Encoder
+
+	// NewDecoder returns a new Decoder that reads from r.
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes encoded values to the underlying stream.
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder reads and decodes values from the underlying stream.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+// JSONCodec is the Codec of "application/json" by using encoding/json.
+type JSONCodec struct{}
+
+// MediaType is the implementation of Codec.
+func (JSONCodec) MediaType() string {
+	return "application/json"
+}
+
+// Marshal is the implementation of Codec. The encoding is followed by a newline character.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Unmarshal is the implementation of Codec.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// NewEncoder is the implementation of Codec.
+func (JSONCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+// NewDecoder is the implementation of Codec.
+func (JSONCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+// XMLCodec is the Codec of "application/xml" by using encoding/xml.
+type XMLCodec struct{}
+
+// MediaType is the implementation of Codec.
+func (XMLCodec) MediaType() string {
+	return "application/xml"
+}
+
+// Marshal is the implementation of Codec. The encoding is followed by a newline character.
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Unmarshal is the implementation of Codec.
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// NewEncoder is the implementation of Codec.
+func (XMLCodec) NewEncoder(w io.Writer) Encoder {
+	return xml.NewEncoder(w)
+}
+
+// NewDecoder is the implementation of Codec.
+func (XMLCodec) NewDecoder(r io.Reader) Decoder {
+	return xml.NewDecoder(r)
+}
+
+// defaultCodecs returns the default codecs.
+func defaultCodecs() []Codec {
+	return []Codec{JSONCodec{}}
+}
+
+// addCodecs adds the given codecs to the list by replacing the codecs which have the same media type.
+func addCodecs(codecs []Codec, newCodecs ...Codec) []Codec {
+	result := make([]Codec, 0, len(codecs)+len(newCodecs))
+	result = append(result, codecs...)
+	for _, newCodec := range newCodecs {
+		if newCodec == nil {
+			continue
+		}
+		replaced := false
+		for i, codec := range result {
+			if strings.EqualFold(codec.MediaType(), newCodec.MediaType()) {
+				result[i] = newCodec
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, newCodec)
+		}
+	}
+	return result
+}
+
+// findCodec returns the codec of the given media type, or nil if not found.
+func findCodec(codecs []Codec, mediaType string) Codec {
+	for _, codec := range codecs {
+		if strings.EqualFold(codec.MediaType(), mediaType) {
+			return codec
+		}
+	}
+	return nil
+}
+
+// codecMediaTypes returns the media types of the given codecs.
+func codecMediaTypes(codecs []Codec) []string {
+	result := make([]string, 0, len(codecs))
+	for _, codec := range codecs {
+		result = append(result, codec.MediaType())
+	}
+	return result
+}
+
+// negotiateCodec returns the codec to encode the response according to the given Accept header.
+// The codec with the highest quality is chosen, and the order of codecs breaks ties.
+// If the Accept header is empty, the first codec is returned. It returns nil if there is no acceptable codec.
+func negotiateCodec(accept string, codecs []Codec) Codec {
+	if len(codecs) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0]
+	}
+
+	ranges := parseAccept(accept)
+
+	var best Codec
+	bestQ := 0.0
+	for _, codec := range codecs {
+		if q := acceptQuality(ranges, codec.MediaType()); q > bestQ {
+			best, bestQ = codec, q
+		}
+	}
+	return best
+}
+
+// acceptRange is the single media range with its quality in Accept header.
+type acceptRange struct {
+	MediaType string
+	Q         float64
+}
+
+// parseAccept parses the given Accept header, and returns media ranges ordered by specificity.
+func parseAccept(accept string) (ranges []acceptRange) {
+	for _, opt := range parseHTTPHeaderOptions(accept) {
+		r := acceptRange{
+			MediaType: strings.ToLower(opt.KeyVals[0].Key),
+			Q:         1,
+		}
+		if s, ok := opt.Map["q"]; ok {
+			q, err := strconv.ParseFloat(s, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			r.Q = q
+		}
+		ranges = append(ranges, r)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return mediaRangeSpecificity(ranges[i].MediaType) > mediaRangeSpecificity(ranges[j].MediaType)
+	})
+	return ranges
+}
+
+// acceptQuality returns the quality of the given media type by the most specific matching media range.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	for _, r := range ranges {
+		switch {
+		case r.MediaType == mediaType:
+		case r.MediaType == "*/*", r.MediaType == "*":
+		case strings.HasSuffix(r.MediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r.MediaType, "*")):
+		default:
+			continue
+		}
+		return r.Q
+	}
+	return 0
+}
+
+// mediaRangeSpecificity returns the specificity of the given media range.
+func mediaRangeSpecificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*", mediaRange == "*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// buildAccept builds the Accept header of the given codecs by decreasing the quality in order.
+func buildAccept(codecs []Codec) string {
+	parts := make([]string, 0, len(codecs))
+	for i, codec := range codecs {
+		part := codec.MediaType()
+		if q := 1 - float64(i)/10; i > 0 && q > 0 {
+			part += ";q=" + strconv.FormatFloat(q, 'f', -1, 64)
+		} else if i > 0 {
+			part += ";q=0.1"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// contentTypeOf returns the Content-Type header value of the given media type.
+// The charset parameter is added for textual media types.
+func contentTypeOf(mediaType string) string {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"):
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
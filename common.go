@@ -1,16 +1,21 @@
 package rapi
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
 
 // Request encapsulates http.Request and gives input from request.
 // It is used in DoFunc and MiddlewareFunc.
 type Request struct {
 	*http.Request
-	In interface{}
+	In         interface{}
+	PathValues map[string]string
 }
 
 // Response encapsulates http.Response and gives data and output from response.
-// It is returned from Caller.Call.
+// It is returned from Caller.Call. The actual response status code is given by StatusCode.
 type Response struct {
 	*http.Response
 	Out interface{}
@@ -24,3 +29,18 @@ type MiddlewareFunc func(req *Request, send SendFunc, next DoFunc)
 
 // SendFunc is a function type to send response in DoFunc or MiddlewareFunc.
 type SendFunc func(out interface{}, code int, headers ...http.Header)
+
+// CallRequest gives the parameters of the call from Caller to InterceptorFunc.
+// The fields can be modified before calling InvokeFunc.
+type CallRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	In     interface{}
+}
+
+// InvokeFunc is a function type to do the call with the given CallRequest in InterceptorFunc.
+type InvokeFunc func(ctx context.Context, req *CallRequest) (*Response, error)
+
+// InterceptorFunc is a function type to intercept calls as middleware from Caller.
+type InterceptorFunc func(ctx context.Context, req *CallRequest, invoke InvokeFunc) (*Response, error)
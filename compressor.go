@@ -0,0 +1,243 @@
+package rapi
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compressor compresses and decompresses the content with the content coding.
+type Compressor interface {
+	// Encoding returns the content coding name such as "gzip".
+	Encoding() string
+
+	// NewWriter returns a new io.WriteCloser that writes the compressed content to w.
+	// The level is the compression level in the meaning of compress/flate, and -1 is the default level.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+
+	// NewReader returns a new io.ReadCloser that reads the decompressed content from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// GzipCompressor is the Compressor of "gzip" content coding by using compress/gzip.
+type GzipCompressor struct{}
+
+// Encoding is the implementation of Compressor.
+func (GzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+// NewWriter is the implementation of Compressor.
+func (GzipCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+
+// NewReader is the implementation of Compressor.
+func (GzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// DeflateCompressor is the Compressor of "deflate" content coding.
+// It writes the zlib format by using compress/zlib, and reads both the zlib and raw deflate formats.
+type DeflateCompressor struct{}
+
+// Encoding is the implementation of Compressor.
+func (DeflateCompressor) Encoding() string {
+	return "deflate"
+}
+
+// NewWriter is the implementation of Compressor.
+func (DeflateCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+// NewReader is the implementation of Compressor.
+func (DeflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	brd := bufio.NewReader(r)
+	if hdr, err := brd.Peek(2); err == nil && hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(brd)
+	}
+	return flate.NewReader(brd), nil
+}
+
+// defaultCompressors returns the default compressors.
+func defaultCompressors() []Compressor {
+	return []Compressor{GzipCompressor{}, DeflateCompressor{}}
+}
+
+// addCompressors adds the given compressors to the list by replacing the compressors which have the same encoding.
+func addCompressors(compressors []Compressor, newCompressors ...Compressor) []Compressor {
+	result := make([]Compressor, 0, len(compressors)+len(newCompressors))
+	result = append(result, compressors...)
+	for _, newCompressor := range newCompressors {
+		if newCompressor == nil {
+			continue
+		}
+		replaced := false
+		for i, compressor := range result {
+			if strings.EqualFold(compressor.Encoding(), newCompressor.Encoding()) {
+				result[i] = newCompressor
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, newCompressor)
+		}
+	}
+	return result
+}
+
+// findCompressor returns the compressor of the given content coding, or nil if not found.
+func findCompressor(compressors []Compressor, encoding string) Compressor {
+	encoding = normalizeEncoding(encoding)
+	for _, compressor := range compressors {
+		if normalizeEncoding(compressor.Encoding()) == encoding {
+			return compressor
+		}
+	}
+	return nil
+}
+
+// normalizeEncoding returns the lower case content coding name by replacing the aliases.
+func normalizeEncoding(encoding string) string {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	// "x-gzip" is considered equivalent to "gzip" as described in RFC 9110 Section 8.4.1.3.
+	if encoding == "x-gzip" {
+		return "gzip"
+	}
+	return encoding
+}
+
+// negotiateCompressor returns the compressor to encode the response according to the given Accept-Encoding header
+// as described in RFC 9110 Section 12.5.3. The compressor with the highest quality is chosen, and the order of
+// compressors breaks ties. It returns nil if no content coding should be applied, including the case that
+// no content coding is acceptable.
+func negotiateCompressor(acceptEncoding string, compressors []Compressor) Compressor {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return nil
+	}
+
+	qualities := make(map[string]float64)
+	for _, opt := range parseHTTPHeaderOptions(acceptEncoding) {
+		q := 1.0
+		if s, ok := opt.Map["q"]; ok {
+			var err error
+			q, err = strconv.ParseFloat(s, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		encoding := normalizeEncoding(opt.KeyVals[0].Key)
+		if _, ok := qualities[encoding]; !ok {
+			qualities[encoding] = q
+		}
+	}
+	quality := func(encoding string) float64 {
+		if q, ok := qualities[encoding]; ok {
+			return q
+		}
+		if q, ok := qualities["*"]; ok {
+			return q
+		}
+		return 0
+	}
+
+	type candidate struct {
+		Compressor Compressor
+		Q          float64
+	}
+	candidates := make([]candidate, 0, len(compressors))
+	for _, compressor := range compressors {
+		if q := quality(normalizeEncoding(compressor.Encoding())); q > 0 {
+			candidates = append(candidates, candidate{Compressor: compressor, Q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Q > candidates[j].Q
+	})
+
+	// identity is acceptable by default, but it's preferred only if its quality is given explicitly and higher.
+	if quality("identity") > candidates[0].Q {
+		return nil
+	}
+
+	return candidates[0].Compressor
+}
+
+// buildAcceptEncoding builds the Accept-Encoding header of the given compressors.
+func buildAcceptEncoding(compressors []Compressor) string {
+	encodings := make([]string, 0, len(compressors))
+	for _, compressor := range compressors {
+		encodings = append(encodings, compressor.Encoding())
+	}
+	return strings.Join(encodings, ", ")
+}
+
+// decompressorsOf returns the compressors to decompress the content according to the given Content-Encoding header.
+// The compressors are in the decoding order that is the reverse order of the header.
+// It returns *InvalidContentEncodingError if any content coding isn't supported by the given compressors.
+func decompressorsOf(contentEncoding string, compressors []Compressor) ([]Compressor, error) {
+	var result []Compressor
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := normalizeEncoding(encodings[i])
+		if encoding == "" || encoding == "identity" {
+			continue
+		}
+		compressor := findCompressor(compressors, encoding)
+		if compressor == nil {
+			return nil, &InvalidContentEncodingError{errors.New("unsupported content encoding"), contentEncoding}
+		}
+		result = append(result, compressor)
+	}
+	return result, nil
+}
+
+// newDecompressReader returns an io.ReadCloser that decompresses r according to the given Content-Encoding header.
+// It returns *InvalidContentEncodingError if any content coding isn't supported by the given compressors.
+func newDecompressReader(r io.Reader, contentEncoding string, compressors []Compressor) (io.ReadCloser, error) {
+	decompressors, err := decompressorsOf(contentEncoding, compressors)
+	if err != nil {
+		return nil, err
+	}
+	result := &decompressReader{
+		Reader: r,
+	}
+	for _, decompressor := range decompressors {
+		rc, err := decompressor.NewReader(result.Reader)
+		if err != nil {
+			_ = result.Close()
+			return nil, &InvalidContentEncodingError{err, contentEncoding}
+		}
+		result.Reader = rc
+		result.closers = append(result.closers, rc)
+	}
+	return result, nil
+}
+
+// decompressReader is the io.ReadCloser that closes all decompressors in the chain.
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close is the implementation of io.Closer.
+func (r *decompressReader) Close() (err error) {
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if e := r.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	r.closers = nil
+	return err
+}
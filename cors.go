@@ -0,0 +1,173 @@
+package rapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions defines the options of Cross-Origin Resource Sharing given to WithCORS.
+type CORSOptions struct {
+	// AllowedOrigins is the list of allowed origins. An origin can be exact such as "https://example.com",
+	// a wildcard such as "https://*.example.com", or "*" to allow all origins.
+	AllowedOrigins []string
+
+	// AllowOriginFunc is a predicate to allow origins in addition to AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowCredentials indicates whether the response can be exposed when the credentials flag is true.
+	AllowCredentials bool
+
+	// AllowedHeaders is the list of request headers allowed in actual requests.
+	// If it's nil or contains "*", the requested headers in preflight requests are allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders is the list of response headers exposed to the client.
+	ExposedHeaders []string
+
+	// MaxAge indicates how long the results of preflight requests can be cached.
+	MaxAge time.Duration
+}
+
+// isOriginAllowed reports whether the given origin is allowed.
+func (o *CORSOptions) isOriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowedOrigin := range o.AllowedOrigins {
+		if allowedOrigin == "*" {
+			return true
+		}
+		if strings.EqualFold(allowedOrigin, origin) {
+			return true
+		}
+		if idx := strings.Index(allowedOrigin, "*"); idx >= 0 {
+			prefix, suffix := strings.ToLower(allowedOrigin[:idx]), strings.ToLower(allowedOrigin[idx+1:])
+			lorigin := strings.ToLower(origin)
+			if len(lorigin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(lorigin, prefix) && strings.HasSuffix(lorigin, suffix) {
+				return true
+			}
+		}
+	}
+	if o.AllowOriginFunc != nil && o.AllowOriginFunc(origin) {
+		return true
+	}
+	return false
+}
+
+// setResponseHeaders sets the CORS headers of actual responses if the origin of the request is allowed.
+func (o *CORSOptions) setResponseHeaders(hdr http.Header, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	addVaryHeader(hdr, "Origin")
+	if !o.isOriginAllowed(origin) {
+		return
+	}
+	o.setOriginHeaders(hdr, origin)
+	if len(o.ExposedHeaders) > 0 {
+		hdr.Set("Access-Control-Expose-Headers", strings.Join(o.ExposedHeaders, ", "))
+	}
+}
+
+// servePreflight responds to the preflight request by using the given allowed methods.
+func (o *CORSOptions) servePreflight(w http.ResponseWriter, r *http.Request, allowedMethods []string,
+	options *handlerOptions) {
+	hdr := w.Header()
+	addVaryHeader(hdr, "Origin")
+	addVaryHeader(hdr, "Access-Control-Request-Method")
+	addVaryHeader(hdr, "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	if !o.isOriginAllowed(origin) {
+		options.PerformError(fmt.Errorf("cors origin %q not allowed", origin), r)
+		options.WriteError(w, r, newStatusTextError(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	requestMethod := r.Header.Get("Access-Control-Request-Method")
+	methodAllowed := false
+	for _, method := range allowedMethods {
+		if method == requestMethod {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		options.PerformError(fmt.Errorf("cors method %s not allowed", requestMethod), r)
+		options.WriteError(w, r, newStatusTextError(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var requestHeaders []string
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			requestHeaders = append(requestHeaders, h)
+		}
+	}
+	allowedHeaders := requestHeaders
+	if o.AllowedHeaders != nil && !containsFold(o.AllowedHeaders, "*") {
+		for _, h := range requestHeaders {
+			if !containsFold(o.AllowedHeaders, h) {
+				options.PerformError(fmt.Errorf("cors header %q not allowed", h), r)
+				options.WriteError(w, r, newStatusTextError(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		allowedHeaders = o.AllowedHeaders
+	}
+
+	o.setOriginHeaders(hdr, origin)
+	hdr.Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+	if len(allowedHeaders) > 0 {
+		hdr.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+	}
+	if o.MaxAge > 0 {
+		hdr.Set("Access-Control-Max-Age", strconv.FormatInt(int64(o.MaxAge/time.Second), 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setOriginHeaders sets the headers of the allowed origin and credentials.
+func (o *CORSOptions) setOriginHeaders(hdr http.Header, origin string) {
+	if containsFold(o.AllowedOrigins, "*") && !o.AllowCredentials {
+		hdr.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		hdr.Set("Access-Control-Allow-Origin", origin)
+	}
+	if o.AllowCredentials {
+		hdr.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// isPreflightRequest reports whether the given request is a CORS preflight request.
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// addVaryHeader adds the given header name to Vary header if it doesn't exist.
+func addVaryHeader(hdr http.Header, name string) {
+	for _, v := range hdr.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s == "*" || strings.EqualFold(s, name) {
+				return
+			}
+		}
+	}
+	hdr.Add("Vary", name)
+}
+
+// containsFold reports whether the given list contains s case-insensitively.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
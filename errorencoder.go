@@ -0,0 +1,69 @@
+package rapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorEncoder is a function type to write the error responses generated by Handler.
+// The given error has the message which is safe to send to the client.
+type ErrorEncoder func(w http.ResponseWriter, r *http.Request, err error, code int)
+
+// ErrorReply is the error output written by DefaultErrorEncoder.
+// It can be given to WithErrOut to get the typed error from Caller.Call.
+type ErrorReply struct {
+	Message string `json:"error"`
+	Status  int    `json:"status,omitempty"`
+}
+
+// Error is the implementation of error.
+func (e *ErrorReply) Error() string {
+	return e.Message
+}
+
+// DefaultErrorEncoder is the default ErrorEncoder of Handler.
+// It writes *ProblemError as problem details, and the error as is if it implements json.Marshaler,
+// otherwise it writes ErrorReply as JSON.
+func DefaultErrorEncoder(w http.ResponseWriter, r *http.Request, err error, code int) {
+	if _, ok := err.(*ProblemError); ok {
+		ProblemErrorEncoder(w, r, err, code)
+		return
+	}
+	if _, ok := err.(json.Marshaler); ok {
+		writeJSON(w, "application/json", err, code)
+		return
+	}
+	writeJSON(w, "application/json", &ErrorReply{
+		Message: err.Error(),
+		Status:  code,
+	}, code)
+}
+
+// NewErrorEncoder returns an ErrorEncoder that writes the output returned from the given function as JSON.
+// It's useful to write errors in the same shape of the error output given to WithErrOut.
+func NewErrorEncoder(f func(err error, code int) interface{}) ErrorEncoder {
+	return func(w http.ResponseWriter, r *http.Request, err error, code int) {
+		writeJSON(w, "application/json", f(err, code), code)
+	}
+}
+
+// writeJSON writes the given output as JSON with the given media type and status code.
+func writeJSON(w http.ResponseWriter, mediaType string, out interface{}, code int) {
+	data, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	data = append(data, '\n')
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	w.Header().Del("Content-Encoding")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+// newStatusTextError creates a new error with the status text of the given status code.
+func newStatusTextError(code int) error {
+	return errors.New(http.StatusText(code))
+}
@@ -1,6 +1,9 @@
 package rapi
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 // InvalidContentTypeError occurs when the request or response body content type is invalid.
 type InvalidContentTypeError struct {
@@ -23,6 +26,27 @@ func (e *InvalidContentTypeError) ContentType() string {
 	return e.contentType
 }
 
+// InvalidContentEncodingError occurs when the request or response body content encoding is invalid or unsupported.
+type InvalidContentEncodingError struct {
+	error           error
+	contentEncoding string
+}
+
+// Error is the implementation of error.
+func (e *InvalidContentEncodingError) Error() string {
+	return fmt.Errorf("invalid content encoding %q: %w", e.contentEncoding, e.error).Error()
+}
+
+// Unwrap unwraps the underlying error.
+func (e *InvalidContentEncodingError) Unwrap() error {
+	return e.error
+}
+
+// ContentEncoding returns the invalid content encoding.
+func (e *InvalidContentEncodingError) ContentEncoding() string {
+	return e.contentEncoding
+}
+
 // RequestError is the request error from http.Client.
 // It is returned from Caller.Call.
 type RequestError struct{ error error }
@@ -50,3 +74,121 @@ func (e *PlainTextError) Error() string {
 func (e *PlainTextError) Unwrap() error {
 	return e.error
 }
+
+// maxStatusErrorBodySize is the maximum size of the response body snippet kept in StatusError.
+const maxStatusErrorBodySize = 1024
+
+// StatusError occurs when the response status code is not success.
+// It is returned from Caller.Call, and it wraps the error decoded from the response body if any,
+// such as the error output given to WithErrOut, *PlainTextError or *ProblemError.
+type StatusError struct {
+	error      error
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// Error is the implementation of error.
+func (e *StatusError) Error() string {
+	if e.error == nil {
+		return fmt.Sprintf("status error: %d %s", e.statusCode, http.StatusText(e.statusCode))
+	}
+	return fmt.Errorf("status error: %d %s: %w", e.statusCode, http.StatusText(e.statusCode), e.error).Error()
+}
+
+// Unwrap unwraps the underlying error.
+func (e *StatusError) Unwrap() error {
+	return e.error
+}
+
+// StatusCode returns the response status code.
+func (e *StatusError) StatusCode() int {
+	return e.statusCode
+}
+
+// Status returns the text of the response status code.
+func (e *StatusError) Status() string {
+	return http.StatusText(e.statusCode)
+}
+
+// Header returns the response headers.
+func (e *StatusError) Header() http.Header {
+	return e.header
+}
+
+// Body returns the beginning of the response body up to 1024 bytes.
+func (e *StatusError) Body() []byte {
+	return e.body
+}
+
+// RetryError occurs when Caller.Call fails after retries by RetryPolicy.
+// It wraps the error of the last attempt.
+type RetryError struct {
+	error    error
+	attempts int
+}
+
+// Error is the implementation of error.
+func (e *RetryError) Error() string {
+	return fmt.Errorf("retry error after %d attempt(s): %w", e.attempts, e.error).Error()
+}
+
+// Unwrap unwraps the underlying error.
+func (e *RetryError) Unwrap() error {
+	return e.error
+}
+
+// Attempts returns the number of attempts.
+func (e *RetryError) Attempts() int {
+	return e.attempts
+}
+
+// PanicError occurs when a panic is recovered in Handler.
+// It is given to the function set by WithOnError.
+type PanicError struct {
+	value interface{}
+	stack []byte
+}
+
+// Error is the implementation of error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic error: %v", e.value)
+}
+
+// Unwrap unwraps the underlying error if the panic value is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.value.(error)
+	return err
+}
+
+// Value returns the recovered panic value.
+func (e *PanicError) Value() interface{} {
+	return e.value
+}
+
+// Stack returns the stack trace of the goroutine where the panic occurred.
+func (e *PanicError) Stack() []byte {
+	return e.stack
+}
+
+// StreamItemError occurs when an item of the streaming request body couldn't be read, decoded or validated.
+// It is returned from InputStream.Err.
+type StreamItemError struct {
+	error error
+	index int
+}
+
+// Error is the implementation of error.
+func (e *StreamItemError) Error() string {
+	return fmt.Errorf("stream item %d error: %w", e.index, e.error).Error()
+}
+
+// Unwrap unwraps the underlying error.
+func (e *StreamItemError) Unwrap() error {
+	return e.error
+}
+
+// Index returns the zero-based index of the item.
+func (e *StreamItemError) Index() int {
+	return e.index
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -19,8 +20,11 @@ func main() {
 			String: "abcdefgh",
 		})
 	if err != nil {
-		out := err.(*messages.ErrorReply)
-		panic(out)
+		var out *messages.ErrorReply
+		if errors.As(err, &out) {
+			panic(out)
+		}
+		panic(err)
 	}
 	out := resp.Out.(*messages.ReverseReply)
 	fmt.Println(out)
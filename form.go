@@ -0,0 +1,326 @@
+package rapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// defaultMaxMultipartMemory is the default maximum memory size to store the multipart form files.
+const defaultMaxMultipartMemory = 32 << 20
+
+// FormFile is the file part of the multipart/form-data request body.
+// The input struct fields of type FormFile, *FormFile, []FormFile or []*FormFile are bound to the file parts
+// with the same field name.
+type FormFile struct {
+	Filename string
+	Header   textproto.MIMEHeader
+	Size     int64
+
+	fh *multipart.FileHeader
+	rd io.Reader
+}
+
+// NewFormFile creates a new FormFile to send the content read from r as the file part of the request body.
+func NewFormFile(filename string, r io.Reader) *FormFile {
+	return &FormFile{
+		Filename: filename,
+		rd:       r,
+	}
+}
+
+// Open opens the received file part. The file is removed after DoFunc returns.
+func (f *FormFile) Open() (multipart.File, error) {
+	if f.fh == nil {
+		return nil, errors.New("form file not received")
+	}
+	return f.fh.Open()
+}
+
+var (
+	formFileType      = reflect.TypeOf(FormFile{})
+	formFilePtrType   = reflect.TypeOf(&FormFile{})
+	formFileSliceType = reflect.TypeOf([]FormFile{})
+	formFilePtrsType  = reflect.TypeOf([]*FormFile{})
+)
+
+// isFormFileType reports whether the given type is able to be bound to the file parts.
+func isFormFileType(typ reflect.Type) bool {
+	switch typ {
+	case formFileType, formFilePtrType, formFileSliceType, formFilePtrsType:
+		return true
+	}
+	return false
+}
+
+// parseForm parses the application/x-www-form-urlencoded or multipart/form-data body read from r.
+// The files exceeding maxMemory are stored on disk. If maxSize is greater than zero, the body is limited by maxSize.
+func parseForm(r io.Reader, contentType string, maxSize, maxMemory int64) (form *multipart.Form, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("media type parse error: %w", err)
+	}
+
+	var lr *io.LimitedReader
+	if maxSize > 0 {
+		lr = &io.LimitedReader{R: r, N: maxSize + 1}
+		r = lr
+	}
+	exceeded := func() bool {
+		return lr != nil && lr.N <= 0
+	}
+
+	switch strings.ToLower(mediaType) {
+	case "application/x-www-form-urlencoded":
+		var data []byte
+		data, err = io.ReadAll(r)
+		if exceeded() {
+			return nil, errors.New("request body too large")
+		}
+		if err != nil {
+			return nil, err
+		}
+		var values url.Values
+		values, err = url.ParseQuery(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return &multipart.Form{Value: values, File: map[string][]*multipart.FileHeader{}}, nil
+
+	case "multipart/form-data":
+		boundary := params["boundary"]
+		if boundary == "" {
+			return nil, errors.New("no multipart boundary")
+		}
+		form, err = multipart.NewReader(r, boundary).ReadForm(maxMemory)
+		if exceeded() {
+			if form != nil {
+				_ = form.RemoveAll()
+			}
+			return nil, errors.New("request body too large")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return form, nil
+	}
+
+	return nil, fmt.Errorf("invalid media type %q", mediaType)
+}
+
+// formToStruct puts form values and files to the given struct fields.
+// Values are put by using valuesToStruct, and files are put to the fields of FormFile types.
+// target must be non-nil struct pointer otherwise it panics.
+func formToStruct(form *multipart.Form, target interface{}) (err error) {
+	err = valuesToStruct(form.Value, target)
+	if err != nil {
+		return err
+	}
+
+	indirectVal := reflect.ValueOf(target).Elem()
+	indirectValType := indirectVal.Type()
+
+	for i, j := 0, indirectValType.NumField(); i < j; i++ {
+		field := indirectValType.Field(i)
+		if !field.IsExported() || field.Anonymous || !isFormFileType(field.Type) {
+			continue
+		}
+
+		fieldName, _ := parseJSONField(field)
+		if fieldName == "" {
+			continue
+		}
+
+		fhs := form.File[fieldName]
+		if len(fhs) == 0 {
+			continue
+		}
+
+		files := make([]*FormFile, 0, len(fhs))
+		for _, fh := range fhs {
+			files = append(files, &FormFile{
+				Filename: fh.Filename,
+				Header:   fh.Header,
+				Size:     fh.Size,
+				fh:       fh,
+			})
+		}
+
+		fieldVal := indirectVal.Field(i)
+		switch field.Type {
+		case formFileType:
+			fieldVal.Set(reflect.ValueOf(*files[0]))
+		case formFilePtrType:
+			fieldVal.Set(reflect.ValueOf(files[0]))
+		case formFileSliceType:
+			result := make([]FormFile, 0, len(files))
+			for _, f := range files {
+				result = append(result, *f)
+			}
+			fieldVal.Set(reflect.ValueOf(result))
+		case formFilePtrsType:
+			fieldVal.Set(reflect.ValueOf(files))
+		}
+	}
+
+	return nil
+}
+
+// structToForm returns url.Values containing struct fields as values, and the files of FormFile fields.
+// Values are created by using structToValues except the fields of FormFile types.
+// source must be nil or struct or struct pointer otherwise it panics.
+func structToForm(source interface{}) (values url.Values, files map[string][]*FormFile, err error) {
+	values, err = structToValues(source)
+	if err != nil {
+		return values, nil, err
+	}
+	files = make(map[string][]*FormFile)
+
+	if source == nil {
+		return values, files, nil
+	}
+
+	val := reflect.ValueOf(source)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return values, files, nil
+		}
+		val = val.Elem()
+	}
+	typ := val.Type()
+
+	for i, j := 0, typ.NumField(); i < j; i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || field.Anonymous || !isFormFileType(field.Type) {
+			continue
+		}
+
+		fieldName, _ := parseJSONField(field)
+		if fieldName == "" {
+			continue
+		}
+		values.Del(fieldName)
+
+		fieldVal := val.Field(i)
+		switch field.Type {
+		case formFileType:
+			f := fieldVal.Interface().(FormFile)
+			if f.rd != nil {
+				files[fieldName] = append(files[fieldName], &f)
+			}
+		case formFilePtrType:
+			if f := fieldVal.Interface().(*FormFile); f != nil && f.rd != nil {
+				files[fieldName] = append(files[fieldName], f)
+			}
+		case formFileSliceType:
+			for _, f := range fieldVal.Interface().([]FormFile) {
+				f := f
+				if f.rd != nil {
+					files[fieldName] = append(files[fieldName], &f)
+				}
+			}
+		case formFilePtrsType:
+			for _, f := range fieldVal.Interface().([]*FormFile) {
+				if f != nil && f.rd != nil {
+					files[fieldName] = append(files[fieldName], f)
+				}
+			}
+		}
+		if len(files[fieldName]) == 0 {
+			delete(files, fieldName)
+		}
+	}
+
+	return values, files, nil
+}
+
+// encodeForm encodes the given values as the form body, and returns the body with its content type.
+// It encodes as multipart/form-data if multipartForm is true, otherwise as application/x-www-form-urlencoded.
+func encodeForm(values url.Values, multipartForm bool) (data []byte, contentType string, err error) {
+	if !multipartForm {
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(buf)
+	if err = writeMultipartForm(mw, values, nil); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+// newMultipartFormBody returns the multipart/form-data body with its content type that streams the given values
+// and files. The files are read while the body is read, and the body is compressed on the fly if compressor isn't nil.
+func newMultipartFormBody(values url.Values, files map[string][]*FormFile, compressor Compressor) (body io.ReadCloser, contentType string) {
+	// the boundary is generated before the body is written to return the content type.
+	boundary := multipart.NewWriter(nil)
+	body = newPipeBody(compressor, func(w io.Writer) error {
+		mw := multipart.NewWriter(w)
+		if err := mw.SetBoundary(boundary.Boundary()); err != nil {
+			return err
+		}
+		return writeMultipartForm(mw, values, files)
+	})
+	return body, boundary.FormDataContentType()
+}
+
+// writeMultipartForm writes the given values and files as the parts of mw, and closes mw.
+func writeMultipartForm(mw *multipart.Writer, values url.Values, files map[string][]*FormFile) (err error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if err = mw.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys = keys[:0]
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, f := range files[k] {
+			hdr := make(textproto.MIMEHeader)
+			for hk, hv := range f.Header {
+				hdr[textproto.CanonicalMIMEHeaderKey(hk)] = append([]string{}, hv...)
+			}
+			hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				escapeQuotes(k), escapeQuotes(f.Filename)))
+			if hdr.Get("Content-Type") == "" {
+				hdr.Set("Content-Type", "application/octet-stream")
+			}
+			var pw io.Writer
+			pw, err = mw.CreatePart(hdr)
+			if err != nil {
+				return err
+			}
+			if _, err = io.Copy(pw, f.rd); err != nil {
+				return fmt.Errorf("unable to read form file %q: %w", f.Filename, err)
+			}
+		}
+	}
+
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes backslashes and double quotes in the quoted-string.
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -3,12 +3,13 @@ package rapi
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,8 +20,12 @@ import (
 // Handler implements http.Handler to process JSON requests based on pattern and registered methods.
 // Handler is similar to http.ServeMux in terms of operation.
 type Handler struct {
-	options  *handlerOptions
-	serveMux *http.ServeMux
+	options           *handlerOptions
+	serveMux          *http.ServeMux
+	templatesMu       sync.RWMutex
+	templates         pathTemplates
+	patternHandlersMu sync.RWMutex
+	patternHandlers   []*patternHandler
 }
 
 // NewHandler creates a new Handler by given HandlerOption's.
@@ -43,16 +48,46 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPatch:
 	case http.MethodDelete:
 	case http.MethodOptions:
-		if h.options.OptionsHandler == nil {
-			h.options.PerformError(fmt.Errorf("method %s handler not defined", r.Method), r)
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		if r.RequestURI != "*" && isPreflightRequest(r) {
+			if handler, _ := h.match(r); handler != nil {
+				if ph, ok := handler.(*patternHandler); ok && ph.options.CORS != nil {
+					ph.options.CORS.servePreflight(w, r, ph.allowedMethods(), ph.options)
+					return
+				}
+			}
+		}
+		if h.options.OptionsHandler != nil {
+			h.options.OptionsHandler.ServeHTTP(w, r)
 			return
 		}
-		h.options.OptionsHandler.ServeHTTP(w, r)
-		return
+		if r.RequestURI == "*" {
+			w.Header().Set("Allow", strings.Join(append(supportedMethods(), http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if handler, _ := h.match(r); handler != nil {
+			if ph, ok := handler.(*patternHandler); ok {
+				if ph.options.CORS != nil {
+					ph.options.CORS.setResponseHeaders(w.Header(), r)
+				}
+				w.Header().Set("Allow", ph.allowHeader())
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
 	default:
+		cors := h.options.CORS
+		if handler, _ := h.match(r); handler != nil {
+			if ph, ok := handler.(*patternHandler); ok {
+				cors = ph.options.CORS
+				w.Header().Set("Allow", ph.allowHeader())
+			}
+		}
+		if cors != nil {
+			cors.setResponseHeaders(w.Header(), r)
+		}
 		h.options.PerformError(fmt.Errorf("method %s not allowed", r.Method), r)
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		h.options.WriteError(w, r, newStatusTextError(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -64,22 +99,75 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handler, pattern := h.serveMux.Handler(r)
-	if h.options.NotFoundHandler != nil && pattern == "" {
-		h.options.NotFoundHandler.ServeHTTP(w, r)
+	handler, r := h.match(r)
+	if handler == nil {
+		if h.options.CORS != nil {
+			h.options.CORS.setResponseHeaders(w.Header(), r)
+		}
+		if h.options.NotFoundHandler != nil {
+			h.options.NotFoundHandler.ServeHTTP(w, r)
+			return
+		}
+		h.options.WriteError(w, r, newStatusTextError(http.StatusNotFound), http.StatusNotFound)
 		return
 	}
 
 	handler.ServeHTTP(w, r)
 }
 
+// match returns the handler that matches the request, or nil if no pattern matches.
+// If the matched pattern has path parameters, the returned request has them in its context.
+func (h *Handler) match(r *http.Request) (http.Handler, *http.Request) {
+	handler, pattern := h.serveMux.Handler(r)
+	if pattern == "" || strings.HasSuffix(pattern, "/") {
+		h.templatesMu.RLock()
+		t, values := h.templates.Match(r.URL.EscapedPath())
+		h.templatesMu.RUnlock()
+		if t != nil {
+			return t.handler, r.WithContext(contextWithPathValues(r.Context(), values))
+		}
+	}
+	if pattern == "" {
+		return nil, r
+	}
+	return handler, r
+}
+
 // Handle creates a Registrar to register methods for the given pattern.
+// The pattern can contain path parameters such as /users/{id} or /files/{path...}, and their values are bound into
+// the input struct fields tagged with "path".
 func (h *Handler) Handle(pattern string, opts ...HandlerOption) Registrar {
-	ph := newPatternHandler(h.options, opts...)
-	h.serveMux.Handle(pattern, ph)
+	ph := newPatternHandler(pattern, h.options, opts...)
+	if isPathTemplate(pattern) {
+		t, err := parsePathTemplate(pattern)
+		if err != nil {
+			panic(fmt.Errorf("unable to parse pattern: %w", err))
+		}
+		t.handler = ph
+		h.templatesMu.Lock()
+		defer h.templatesMu.Unlock()
+		err = h.templates.Add(t)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		h.serveMux.Handle(pattern, ph)
+	}
+	h.patternHandlersMu.Lock()
+	h.patternHandlers = append(h.patternHandlers, ph)
+	h.patternHandlersMu.Unlock()
 	return &struct{ Registrar }{ph}
 }
 
+// getPatternHandlers returns the list of patternHandler's in the registration order.
+func (h *Handler) getPatternHandlers() []*patternHandler {
+	h.patternHandlersMu.RLock()
+	defer h.patternHandlersMu.RUnlock()
+	result := make([]*patternHandler, len(h.patternHandlers))
+	copy(result, h.patternHandlers)
+	return result
+}
+
 // Registrar is method registrar and created by Handler.Handle.
 type Registrar interface {
 	// Register registers method with the given parameters to Handler. The pattern was given from Handler.Handle.
@@ -87,13 +175,16 @@ type Registrar interface {
 }
 
 type patternHandler struct {
+	pattern          string
 	options          *handlerOptions
 	methodHandlersMu sync.RWMutex
 	methodHandlers   map[string]*methodHandler
+	methods          []string
 }
 
-func newPatternHandler(options *handlerOptions, opts ...HandlerOption) (h *patternHandler) {
+func newPatternHandler(pattern string, options *handlerOptions, opts ...HandlerOption) (h *patternHandler) {
 	h = &patternHandler{
+		pattern:        pattern,
 		options:        options.Clone(),
 		methodHandlers: make(map[string]*methodHandler),
 	}
@@ -102,6 +193,10 @@ func newPatternHandler(options *handlerOptions, opts ...HandlerOption) (h *patte
 }
 
 func (h *patternHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.options.CORS != nil {
+		h.options.CORS.setResponseHeaders(w.Header(), r)
+	}
+
 	h.methodHandlersMu.RLock()
 	mh := h.methodHandlers[r.Method]
 	if mh == nil {
@@ -110,8 +205,9 @@ func (h *patternHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.methodHandlersMu.RUnlock()
 
 	if mh == nil {
+		w.Header().Set("Allow", h.allowHeader())
 		h.options.PerformError(fmt.Errorf("method %s not registered", r.Method), r)
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		h.options.WriteError(w, r, newStatusTextError(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -142,7 +238,14 @@ func (h *patternHandler) Register(method string, in interface{}, do DoFunc, opts
 		panic(fmt.Errorf("method %q already registered", method))
 	}
 	mh = newMethodhandler(in, do, h.options, opts...)
+	if mh.options.StreamInput && (method == http.MethodGet || method == http.MethodDelete) {
+		panic(fmt.Errorf("stream input not allowed for method %q", method))
+	}
+	if mh.options.FormBody && !mh.options.StreamInput && inVal.Elem().Kind() != reflect.Struct {
+		panic(errors.New("input must be struct or struct pointer for form body"))
+	}
 	h.methodHandlers[method] = mh
+	h.methods = append(h.methods, method)
 	if method == http.MethodGet {
 		h.methodHandlers[http.MethodHead] = mh
 	}
@@ -150,6 +253,47 @@ func (h *patternHandler) Register(method string, in interface{}, do DoFunc, opts
 	return &struct{ Registrar }{h}
 }
 
+// allowedMethods returns the methods allowed by the registrations including implicit HEAD for GET.
+func (h *patternHandler) allowedMethods() []string {
+	methods, _ := h.getMethodHandlers()
+	registered := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		registered[method] = struct{}{}
+	}
+	var result []string
+	for _, method := range supportedMethods() {
+		_, ok := registered[method]
+		if !ok && method == http.MethodHead {
+			_, ok = registered[http.MethodGet]
+		}
+		if !ok {
+			_, ok = registered[""]
+		}
+		if ok {
+			result = append(result, method)
+		}
+	}
+	return result
+}
+
+// allowHeader returns the value of Allow header including OPTIONS.
+func (h *patternHandler) allowHeader() string {
+	return strings.Join(append(h.allowedMethods(), http.MethodOptions), ", ")
+}
+
+// getMethodHandlers returns the registered methods in the registration order and their methodHandler's.
+func (h *patternHandler) getMethodHandlers() (methods []string, methodHandlers []*methodHandler) {
+	h.methodHandlersMu.RLock()
+	defer h.methodHandlersMu.RUnlock()
+	methods = make([]string, len(h.methods))
+	copy(methods, h.methods)
+	methodHandlers = make([]*methodHandler, 0, len(methods))
+	for _, method := range methods {
+		methodHandlers = append(methodHandlers, h.methodHandlers[method])
+	}
+	return methods, methodHandlers
+}
+
 type methodHandler struct {
 	options *handlerOptions
 	in      interface{}
@@ -163,14 +307,74 @@ func newMethodhandler(in interface{}, do DoFunc, options *handlerOptions, opts .
 		do:      do,
 	}
 	newJoinHandlerOption(opts...).apply(h.options)
+	if h.options.Validation && in != nil {
+		if err := checkValidationRules(reflect.TypeOf(in)); err != nil {
+			panic(fmt.Errorf("invalid validation rules: %w", err))
+		}
+	}
 	return h
 }
 
 func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var err error
 
+	if h.options.Recovery {
+		rw := &responseWriter{ResponseWriter: w}
+		w = rw
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			h.options.PerformError(&PanicError{value: v, stack: debug.Stack()}, r)
+			if !rw.wroteHeader {
+				h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+	}
+
 	req := &Request{
-		Request: r,
+		Request:    r,
+		PathValues: pathValuesFromContext(r.Context()),
+	}
+
+	reqCodec := h.options.Codecs[0]
+	contentType := r.Header.Get("Content-Type")
+	var mediaType string
+	if contentType != "" {
+		validMediaTypes := codecMediaTypes(h.options.Codecs)
+		if h.options.StreamInput {
+			validMediaTypes = []string{"application/json", StreamNDJSON.MediaType()}
+		} else if h.options.FormBody {
+			validMediaTypes = append(validMediaTypes, "application/x-www-form-urlencoded", "multipart/form-data")
+		}
+		mediaType, _, err = validateContentType(contentType, validMediaTypes...)
+		if err != nil {
+			h.options.PerformError(&InvalidContentTypeError{err, contentType}, r)
+			h.options.WriteError(w, r, errors.New("invalid content type"), http.StatusBadRequest)
+			return
+		}
+		reqCodec = findCodec(h.options.Codecs, mediaType)
+	}
+
+	contentEncoding := r.Header.Get("Content-Encoding")
+	if contentEncoding != "" {
+		_, err = decompressorsOf(contentEncoding, h.options.Compressors)
+		if err != nil {
+			h.options.PerformError(err, r)
+			h.options.WriteError(w, r, errors.New("unsupported content encoding"), http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	respCodec := negotiateCodec(r.Header.Get("Accept"), h.options.Codecs)
+	if !h.acceptable(r.Header.Get("Accept"), respCodec) {
+		h.options.PerformError(fmt.Errorf("no acceptable media type for %q", r.Header.Get("Accept")), r)
+		h.options.WriteError(w, r, errors.New("not acceptable"), http.StatusNotAcceptable)
+		return
 	}
 
 	var sent int32
@@ -181,44 +385,75 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			panic(errors.New("already sent"))
 		}
 
+		if !bodyAllowedForStatus(code) {
+			addResponseHeaders(w.Header(), headers...)
+			w.WriteHeader(code)
+			return
+		}
+
+		if stream, ok := out.(*Stream); ok && stream != nil {
+			h.sendStream(w, r, stream, code, headers...)
+			return
+		}
+		if raw, ok := out.(*RawContent); ok && raw != nil {
+			h.sendRaw(w, r, raw, code, headers...)
+			return
+		}
+
+		codec := respCodec
+		switch p := out.(type) {
+		case *ProblemDetails:
+			codec = problemCodec{}
+			if p != nil && p.Status == 0 {
+				p2 := *p
+				p2.Status = code
+				out = p2
+			}
+		case ProblemDetails:
+			codec = problemCodec{}
+			if p.Status == 0 {
+				p.Status = code
+				out = p
+			}
+		}
+		if codec == nil {
+			h.options.PerformError(fmt.Errorf("no acceptable media type for %q", r.Header.Get("Accept")), r)
+			h.options.WriteError(w, r, errors.New("not acceptable"), http.StatusNotAcceptable)
+			return
+		}
+
 		var data []byte
-		data, err = json.Marshal(out)
+		data, err = codec.Marshal(out)
 		if err != nil {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
 			panic(fmt.Errorf("unable to encode output: %w", err))
 		}
-		data = append(data, '\n')
 
-		var nopcw io.WriteCloser = nopCloserForWriter{w}
-		wc := nopcw
-		if h.options.AllowEncoding {
-			wc, err = getContentEncoder(w, r.Header.Get("Accept-Encoding"))
+		var compressor Compressor
+		if h.options.AllowEncoding && len(data) >= h.options.MinCompressionSize {
+			compressor = negotiateCompressor(r.Header.Get("Accept-Encoding"), h.options.Compressors)
+		}
+
+		var wc io.WriteCloser = nopCloserForWriter{w}
+		if compressor != nil {
+			wc, err = compressor.NewWriter(w, h.options.CompressionLevel)
 			if err != nil {
-				h.options.PerformError(fmt.Errorf("unable to get content encoder: %w", err), r)
-				http.Error(w, "invalid accept encoding", http.StatusBadRequest)
-				return
+				h.options.PerformError(fmt.Errorf("unable to create compressor %q: %w", compressor.Encoding(), err), r)
+				compressor, wc = nil, nopCloserForWriter{w}
 			}
 		}
 
-		for _, hdr := range headers {
-			for k, v := range hdr {
-				lk := strings.ToLower(k)
-				if lk == "accept" || strings.HasPrefix(lk, "accept-") {
-					continue
-				}
-				if lk == "content" || strings.HasPrefix(lk, "content-") {
-					continue
-				}
-				if lk == "transfer" || strings.HasPrefix(lk, "transfer-") {
-					continue
-				}
-				for _, v2 := range v {
-					w.Header().Add(k, v2)
-				}
-			}
+		addResponseHeaders(w.Header(), headers...)
+		if len(h.options.Codecs) > 1 {
+			addVaryHeader(w.Header(), "Accept")
+		}
+		if h.options.AllowEncoding {
+			addVaryHeader(w.Header(), "Accept-Encoding")
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		if wc == nopcw {
+		w.Header().Set("Content-Type", contentTypeOf(codec.MediaType()))
+		if compressor != nil {
+			w.Header().Set("Content-Encoding", compressor.Encoding())
+		} else {
 			w.Header().Set("Content-Length", strconv.FormatInt(int64(len(data)), 10))
 		}
 		w.WriteHeader(code)
@@ -226,22 +461,7 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		respCtx, respCancel := context.WithCancel(context.Background())
-		defer respCancel()
-
-		if h.options.WriteTimeout > 0 {
-			go func() {
-				select {
-				case <-time.After(h.options.WriteTimeout):
-					respCancel()
-				case <-respCtx.Done():
-				}
-			}()
-		}
-
-		go func() {
-			defer respCancel()
-
+		h.writeWithTimeout(func() {
 			var err error
 
 			_, err = io.Copy(wc, bytes.NewBuffer(data))
@@ -255,44 +475,46 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				h.options.PerformError(fmt.Errorf("unable to write end of response body: %w", err), r)
 				return
 			}
-		}()
-
-		<-respCtx.Done()
-	}
-
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "" {
-		_, _, err = validateContentType(contentType, "application/json")
-		if err != nil {
-			h.options.PerformError(&InvalidContentTypeError{err, contentType}, r)
-			http.Error(w, "invalid content type", http.StatusBadRequest)
-			return
-		}
+		})
 	}
 
 	inVal := reflect.ValueOf(h.in)
 	copiedInVal, err := copyReflectValue(inVal)
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
 		panic(fmt.Errorf("unable to copy input: %w", err))
 	}
 
-	if contentType == "" &&
+	var stream *InputStream
+	if h.options.StreamInput {
+		var body io.ReadCloser
+		body, err = newDecompressReader(&idleTimeoutReader{body: r.Body, timeout: h.options.ReadTimeout},
+			contentEncoding, h.options.Compressors)
+		if err != nil {
+			h.options.PerformError(fmt.Errorf("unable to decode request body: %w", err), r)
+			h.options.WriteError(w, r, errors.New("unable to decode request body"), http.StatusBadRequest)
+			return
+		}
+		defer func() {
+			_ = body.Close()
+		}()
+		stream = newInputStream(body, mediaType == StreamNDJSON.MediaType(), h.in, h.options)
+	} else if contentType == "" &&
 		(r.Method == http.MethodHead || r.Method == http.MethodGet || r.Method == http.MethodDelete) {
 		if copiedInVal.Elem().Kind() != reflect.Struct {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
 			panic(errors.New("input must be struct or struct pointer"))
 		}
 		err = valuesToStruct(r.URL.Query(), copiedInVal.Interface())
 		if err != nil {
 			h.options.PerformError(fmt.Errorf("invalid query: %w", err), r)
-			http.Error(w, "invalid query", http.StatusBadRequest)
+			h.options.WriteError(w, r, errors.New("invalid query"), http.StatusBadRequest)
 			return
 		}
-	} else {
-		var rd io.Reader = r.Body
-		if h.options.MaxRequestBodySize > 0 {
-			rd = io.LimitReader(r.Body, h.options.MaxRequestBodySize)
+	} else if reqCodec == nil {
+		if copiedInVal.Elem().Kind() != reflect.Struct {
+			h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
+			panic(errors.New("input must be struct or struct pointer"))
 		}
 		completed := make(chan struct{})
 		if h.options.ReadTimeout > 0 {
@@ -304,11 +526,62 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 			}()
 		}
-		err = json.NewDecoder(rd).Decode(copiedInVal.Interface())
+		var form *multipart.Form
+		var body io.ReadCloser
+		body, err = newDecompressReader(r.Body, contentEncoding, h.options.Compressors)
+		if err == nil {
+			form, err = parseForm(body, contentType, h.options.MaxRequestBodySize, h.options.MaxMultipartMemory)
+			_ = body.Close()
+		}
 		close(completed)
 		if err != nil {
 			h.options.PerformError(fmt.Errorf("unable to decode request body: %w", err), r)
-			http.Error(w, "unable to decode request body", http.StatusBadRequest)
+			h.options.WriteError(w, r, errors.New("unable to decode request body"), http.StatusBadRequest)
+			return
+		}
+		defer func() {
+			_ = form.RemoveAll()
+		}()
+		err = formToStruct(form, copiedInVal.Interface())
+		if err != nil {
+			h.options.PerformError(fmt.Errorf("invalid form: %w", err), r)
+			h.options.WriteError(w, r, errors.New("invalid form"), http.StatusBadRequest)
+			return
+		}
+	} else {
+		completed := make(chan struct{})
+		if h.options.ReadTimeout > 0 {
+			go func() {
+				select {
+				case <-time.After(h.options.ReadTimeout):
+					_ = r.Body.Close()
+				case <-completed:
+				}
+			}()
+		}
+		var body io.ReadCloser
+		body, err = newDecompressReader(r.Body, contentEncoding, h.options.Compressors)
+		if err == nil {
+			var rd io.Reader = body
+			if h.options.MaxRequestBodySize > 0 {
+				rd = io.LimitReader(body, h.options.MaxRequestBodySize)
+			}
+			err = reqCodec.NewDecoder(rd).Decode(copiedInVal.Interface())
+			_ = body.Close()
+		}
+		close(completed)
+		if err != nil {
+			h.options.PerformError(fmt.Errorf("unable to decode request body: %w", err), r)
+			h.options.WriteError(w, r, errors.New("unable to decode request body"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if stream == nil && req.PathValues != nil && copiedInVal.Elem().Kind() == reflect.Struct {
+		err = pathValuesToStruct(req.PathValues, copiedInVal.Interface())
+		if err != nil {
+			h.options.PerformError(fmt.Errorf("invalid path: %w", err), r)
+			h.options.WriteError(w, r, newStatusTextError(http.StatusNotFound), http.StatusNotFound)
 			return
 		}
 	}
@@ -320,7 +593,24 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		in = copiedInVal.Elem().Interface()
 	}
 
+	if stream == nil && h.options.Validation {
+		err = validateStruct(copiedInVal.Interface())
+		if err != nil {
+			h.options.PerformError(err, r)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			h.options.WriteError(w, r, validationErr, http.StatusBadRequest)
+			return
+		}
+	}
+
 	req.In = in
+	if stream != nil {
+		req.In = stream
+	}
 
 	do := []DoFunc{
 		func(req *Request, send SendFunc) {
@@ -341,7 +631,60 @@ func (h *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	do[len(do)-1](req, send)
 
 	if sent == 0 {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
 		panic(errors.New("send must be called"))
 	}
 }
+
+// acceptable reports whether the given Accept header allows any response of the method before calling DoFunc.
+// If stream outputs are declared, only their media types are acceptable. Otherwise, the negotiated codec and
+// the media types of all stream formats are acceptable. Any media type is acceptable if RawContent is declared
+// as an output.
+func (h *methodHandler) acceptable(accept string, respCodec Codec) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	for _, out := range h.options.Outputs {
+		if out != nil && indirectType(reflect.TypeOf(out)) == reflect.TypeOf(RawContent{}) {
+			return true
+		}
+	}
+	formats := h.options.StreamOutputs
+	if len(formats) == 0 {
+		if respCodec != nil {
+			return true
+		}
+		formats = []StreamFormat{StreamNDJSON, StreamSSE}
+	}
+	ranges := parseAccept(accept)
+	for _, format := range formats {
+		if acceptQuality(ranges, format.MediaType()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeWithTimeout calls f to write the response body in a new goroutine,
+// and waits until f returns or the write timeout exceeds.
+func (h *methodHandler) writeWithTimeout(f func()) {
+	respCtx, respCancel := context.WithCancel(context.Background())
+	defer respCancel()
+
+	if h.options.WriteTimeout > 0 {
+		go func() {
+			select {
+			case <-time.After(h.options.WriteTimeout):
+				respCancel()
+			case <-respCtx.Done():
+			}
+		}()
+	}
+
+	go func() {
+		defer respCancel()
+		f()
+	}()
+
+	<-respCtx.Done()
+}
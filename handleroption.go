@@ -1,6 +1,8 @@
 package rapi
 
 import (
+	"compress/flate"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -49,11 +51,29 @@ type handlerOptions struct {
 	AllowEncoding      bool
 	OptionsHandler     http.Handler
 	NotFoundHandler    http.Handler
+	Validation         bool
+	ErrorEncoder       ErrorEncoder
+	Recovery           bool
+	Outputs            map[int]interface{}
+	CORS               *CORSOptions
+	Codecs             []Codec
+	FormBody           bool
+	MaxMultipartMemory int64
+	Compressors        []Compressor
+	CompressionLevel   int
+	MinCompressionSize int
+	StreamInput        bool
+	StreamOutputs      []StreamFormat
 }
 
 func newHandlerOptions() (o *handlerOptions) {
 	return &handlerOptions{
-		AllowEncoding: true,
+		AllowEncoding:      true,
+		Outputs:            map[int]interface{}{},
+		Codecs:             defaultCodecs(),
+		MaxMultipartMemory: defaultMaxMultipartMemory,
+		Compressors:        defaultCompressors(),
+		CompressionLevel:   -1,
 	}
 }
 
@@ -70,8 +90,27 @@ func (o *handlerOptions) Clone() *handlerOptions {
 		AllowEncoding:      o.AllowEncoding,
 		OptionsHandler:     o.OptionsHandler,
 		NotFoundHandler:    o.NotFoundHandler,
+		Validation:         o.Validation,
+		ErrorEncoder:       o.ErrorEncoder,
+		Recovery:           o.Recovery,
+		Outputs:            make(map[int]interface{}, len(o.Outputs)),
+		CORS:               o.CORS,
+		Codecs:             make([]Codec, len(o.Codecs)),
+		FormBody:           o.FormBody,
+		MaxMultipartMemory: o.MaxMultipartMemory,
+		Compressors:        make([]Compressor, len(o.Compressors)),
+		CompressionLevel:   o.CompressionLevel,
+		MinCompressionSize: o.MinCompressionSize,
+		StreamInput:        o.StreamInput,
+		StreamOutputs:      make([]StreamFormat, len(o.StreamOutputs)),
 	}
 	copy(result.Middlewares, o.Middlewares)
+	copy(result.Codecs, o.Codecs)
+	copy(result.Compressors, o.Compressors)
+	copy(result.StreamOutputs, o.StreamOutputs)
+	for k, v := range o.Outputs {
+		result.Outputs[k] = v
+	}
 	return result
 }
 
@@ -81,6 +120,14 @@ func (o *handlerOptions) PerformError(err error, req *http.Request) {
 	}
 }
 
+func (o *handlerOptions) WriteError(w http.ResponseWriter, req *http.Request, err error, code int) {
+	if o.ErrorEncoder != nil {
+		o.ErrorEncoder(w, req, err, code)
+		return
+	}
+	DefaultErrorEncoder(w, req, err, code)
+}
+
 // WithOnError returns a HandlerOption that sets the function to be called on error.
 func WithOnError(onError func(error, *http.Request)) HandlerOption {
 	return newFuncHandlerOption(func(options *handlerOptions) {
@@ -96,6 +143,7 @@ func WithMiddleware(middlewares ...MiddlewareFunc) HandlerOption {
 }
 
 // WithMaxRequestBodySize returns a HandlerOption that limits maximum request body size.
+// The limit is applied to the decompressed request body.
 func WithMaxRequestBodySize(maxRequestBodySize int64) HandlerOption {
 	return newFuncHandlerOption(func(options *handlerOptions) {
 		options.MaxRequestBodySize = maxRequestBodySize
@@ -110,6 +158,7 @@ func WithReadTimeout(readTimeout time.Duration) HandlerOption {
 }
 
 // WithWriteTimeout returns a HandlerOption that limits maximum response body write duration.
+// On streaming responses, it limits the write duration of each item.
 func WithWriteTimeout(writeTimeout time.Duration) HandlerOption {
 	return newFuncHandlerOption(func(options *handlerOptions) {
 		options.WriteTimeout = writeTimeout
@@ -117,7 +166,8 @@ func WithWriteTimeout(writeTimeout time.Duration) HandlerOption {
 }
 
 // WithAllowEncoding returns a HandlerOption that allows encoded content types such as gzip to be returned.
-// By default, encoding is allowed.
+// The content coding is negotiated by Accept-Encoding header among the registered compressors,
+// and Vary header is set with Accept-Encoding. By default, encoding is allowed.
 func WithAllowEncoding(allowEncoding bool) HandlerOption {
 	return newFuncHandlerOption(func(options *handlerOptions) {
 		options.AllowEncoding = allowEncoding
@@ -125,6 +175,7 @@ func WithAllowEncoding(allowEncoding bool) HandlerOption {
 }
 
 // WithOptionsHandler returns a HandlerOption that handles requests with method OPTIONS.
+// If it isn't defined, OPTIONS requests are answered with Allow header listing the methods registered for the pattern.
 func WithOptionsHandler(optionsHandler http.Handler) HandlerOption {
 	return newFuncHandlerOption(func(options *handlerOptions) {
 		options.OptionsHandler = optionsHandler
@@ -137,3 +188,137 @@ func WithNotFoundHandler(notFoundHandler http.Handler) HandlerOption {
 		options.NotFoundHandler = notFoundHandler
 	})
 }
+
+// WithValidation returns a HandlerOption that validates the input according to "validate" struct tags.
+// The supported rules are required, min, max, len, pattern, oneof, email and dive.
+// If the validation fails, *ValidationError is written with 400 Bad Request by the ErrorEncoder,
+// and DefaultErrorEncoder writes it as the list of field errors.
+// Registering a method panics if the rules are invalid or not applicable on the kinds of their fields.
+// By default, validation is disabled.
+func WithValidation(validation bool) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.Validation = validation
+	})
+}
+
+// WithErrorEncoder returns a HandlerOption that sets the ErrorEncoder to write the error responses generated by Handler.
+// By default, DefaultErrorEncoder is used.
+func WithErrorEncoder(errorEncoder ErrorEncoder) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.ErrorEncoder = errorEncoder
+	})
+}
+
+// WithRecovery returns a HandlerOption that recovers panics in DoFunc's and MiddlewareFunc's.
+// The recovered panic is given to OnError as *PanicError, and 500 Internal Server Error is written by ErrorEncoder
+// unless the response header was already written.
+// By default, recovery is disabled.
+func WithRecovery(recovery bool) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.Recovery = recovery
+	})
+}
+
+// WithOutput returns a HandlerOption that declares the output type sent with the given status code.
+// The status code 0 declares the default output for undeclared status codes.
+// Declared outputs are used in the OpenAPI document, and they don't affect the responses.
+func WithOutput(statusCode int, out interface{}) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.Outputs[statusCode] = out
+	})
+}
+
+// WithCORS returns a HandlerOption that enables Cross-Origin Resource Sharing with the given options.
+// Preflight requests are answered automatically by using the methods registered for the pattern, and
+// the CORS headers are added to the actual responses including error responses.
+// It's effective on Handler and Handler.Handle. If cors is nil, CORS is disabled.
+func WithCORS(cors *CORSOptions) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.CORS = cors
+	})
+}
+
+// WithCodec returns a HandlerOption that sets the codecs to decode request bodies and encode responses
+// in the order of preference.
+// Request bodies are decoded by the codec of Content-Type, and responses are encoded by the codec negotiated
+// by Accept header. The first codec is used if Content-Type or Accept header is absent.
+// If Accept header allows none of the codecs and streams, the request is answered with 406 before calling DoFunc.
+// By default, only JSONCodec is used. If no codecs are given, the default is restored.
+func WithCodec(codecs ...Codec) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.Codecs = addCodecs(nil, codecs...)
+		if len(options.Codecs) == 0 {
+			options.Codecs = defaultCodecs()
+		}
+	})
+}
+
+// WithFormBody returns a HandlerOption that accepts application/x-www-form-urlencoded and multipart/form-data
+// request bodies. Form values are bound into the input struct fields in the same way as query strings,
+// and file parts are bound into the fields of FormFile types.
+// The input must be a struct or struct pointer if form bodies are accepted.
+// By default, form bodies are not accepted.
+func WithFormBody(formBody bool) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.FormBody = formBody
+	})
+}
+
+// WithStreamInput returns a HandlerOption that reads the request body as a stream of items.
+// The input given to Register is the item type, and Request.In is *InputStream to iterate the decoded items.
+// The request body must be NDJSON or a top-level JSON array. The maximum request body size is applied to each item,
+// and the read timeout is applied as the idle timeout while reading the request body.
+// It's effective on methods that have request bodies.
+func WithStreamInput(streamInput bool) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.StreamInput = streamInput
+	})
+}
+
+// WithStreamOutput returns a HandlerOption that declares the method responds with Stream of the given formats.
+// Accept header is checked against the media types of the formats before calling DoFunc, and the request is
+// answered with 406 if it allows none of them. By default, streams aren't declared, and Accept header is checked
+// against the stream formats when the stream is sent.
+func WithStreamOutput(formats ...StreamFormat) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.StreamOutputs = append([]StreamFormat(nil), formats...)
+	})
+}
+
+// WithMaxMultipartMemory returns a HandlerOption that limits the memory size to store the multipart form files.
+// The files exceeding the limit are stored in temporary files on disk. By default, the limit is 32 MB.
+func WithMaxMultipartMemory(maxMultipartMemory int64) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.MaxMultipartMemory = maxMultipartMemory
+	})
+}
+
+// WithCompressor returns a HandlerOption that adds compressors to encode responses and decode request bodies.
+// A compressor replaces the registered compressor which has the same content coding.
+// Request bodies with unsupported Content-Encoding are answered with 415.
+// By default, GzipCompressor and DeflateCompressor are registered in order.
+func WithCompressor(compressors ...Compressor) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.Compressors = addCompressors(options.Compressors, compressors...)
+	})
+}
+
+// WithCompressionLevel returns a HandlerOption that sets the compression level of responses.
+// The level is in the meaning of compress/flate, and by default, it's -1 that is the default compression level.
+// It panics if the level isn't between flate.HuffmanOnly and flate.BestCompression.
+func WithCompressionLevel(compressionLevel int) HandlerOption {
+	if compressionLevel < flate.HuffmanOnly || compressionLevel > flate.BestCompression {
+		panic(fmt.Errorf("invalid compression level %d", compressionLevel))
+	}
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.CompressionLevel = compressionLevel
+	})
+}
+
+// WithMinCompressionSize returns a HandlerOption that skips compression of responses smaller than the given size.
+// By default, all responses are compressed.
+func WithMinCompressionSize(minCompressionSize int) HandlerOption {
+	return newFuncHandlerOption(func(options *handlerOptions) {
+		options.MinCompressionSize = minCompressionSize
+	})
+}
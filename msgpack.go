@@ -0,0 +1,352 @@
+package rapi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// MessagePackCodec is the Codec of "application/msgpack" described in the MessagePack specification.
+// Values are encoded by following the encoding/json rules such as "json" struct tags and omitempty.
+// time.Time is encoded as RFC 3339 string, and []byte is encoded as binary.
+// The timestamp extension type is also accepted while decoding time.Time.
+type MessagePackCodec struct{}
+
+// MediaType is the implementation of Codec.
+func (MessagePackCodec) MediaType() string {
+	return "application/msgpack"
+}
+
+// Marshal is the implementation of Codec.
+func (MessagePackCodec) Marshal(v interface{}) ([]byte, error) {
+	w := &msgpackWriter{}
+	if err := encodeBinaryValue(w, reflect.ValueOf(v), 0); err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return w.buf, nil
+}
+
+// Unmarshal is the implementation of Codec.
+func (MessagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := unmarshalBinary(data, v, readMsgpack); err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	return nil
+}
+
+// NewEncoder is the implementation of Codec.
+func (c MessagePackCodec) NewEncoder(w io.Writer) Encoder {
+	return &binaryEncoder{w: w, marshal: c.Marshal}
+}
+
+// NewDecoder is the implementation of Codec.
+func (MessagePackCodec) NewDecoder(r io.Reader) Decoder {
+	return &binaryDecoder{r: bufio.NewReader(r), read: readMsgpack}
+}
+
+// msgpackWriter is the binaryWriter of MessagePack.
+type msgpackWriter struct {
+	buf []byte
+}
+
+func (w *msgpackWriter) writeNil() {
+	w.buf = append(w.buf, 0xc0)
+}
+
+func (w *msgpackWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, 0xc3)
+	} else {
+		w.buf = append(w.buf, 0xc2)
+	}
+}
+
+func (w *msgpackWriter) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		w.writeUint(uint64(i))
+	case i >= -32:
+		w.buf = append(w.buf, byte(i))
+	case i >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		w.buf = append(w.buf, 0xd1)
+		w.buf = appendUint16(w.buf, uint16(i))
+	case i >= math.MinInt32:
+		w.buf = append(w.buf, 0xd2)
+		w.buf = appendUint32(w.buf, uint32(i))
+	default:
+		w.buf = append(w.buf, 0xd3)
+		w.buf = appendUint64(w.buf, uint64(i))
+	}
+}
+
+func (w *msgpackWriter) writeUint(u uint64) {
+	switch {
+	case u < 0x80:
+		w.buf = append(w.buf, byte(u))
+	case u <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		w.buf = append(w.buf, 0xcd)
+		w.buf = appendUint16(w.buf, uint16(u))
+	case u <= math.MaxUint32:
+		w.buf = append(w.buf, 0xce)
+		w.buf = appendUint32(w.buf, uint32(u))
+	default:
+		w.buf = append(w.buf, 0xcf)
+		w.buf = appendUint64(w.buf, u)
+	}
+}
+
+func (w *msgpackWriter) writeFloat(f float64, bitSize int) {
+	if bitSize == 32 {
+		w.buf = append(w.buf, 0xca)
+		w.buf = appendUint32(w.buf, math.Float32bits(float32(f)))
+		return
+	}
+	w.buf = append(w.buf, 0xcb)
+	w.buf = appendUint64(w.buf, math.Float64bits(f))
+}
+
+func (w *msgpackWriter) writeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		w.buf = append(w.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xda)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdb)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, s...)
+}
+
+func (w *msgpackWriter) writeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xc5)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xc6)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, b...)
+}
+
+func (w *msgpackWriter) writeArrayHeader(n int) {
+	switch {
+	case n < 16:
+		w.buf = append(w.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xdc)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdd)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+}
+
+func (w *msgpackWriter) writeMapHeader(n int) {
+	switch {
+	case n < 16:
+		w.buf = append(w.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xde)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdf)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+}
+
+// readMsgpack is the binaryReadFunc of MessagePack.
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	return readMsgpackValue(r, 0)
+}
+
+func readMsgpackValue(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("exceeded max depth")
+	}
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	readUint := func(size int) (uint64, error) {
+		data, err := readBinaryN(r, uint64(size))
+		if err != nil {
+			return 0, err
+		}
+		switch size {
+		case 1:
+			return uint64(data[0]), nil
+		case 2:
+			return uint64(binary.BigEndian.Uint16(data)), nil
+		case 4:
+			return uint64(binary.BigEndian.Uint32(data)), nil
+		}
+		return binary.BigEndian.Uint64(data), nil
+	}
+
+	var n uint64
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return readMsgpackMap(r, uint64(c&0x0f), depth)
+	case c >= 0x90 && c <= 0x9f:
+		return readMsgpackArray(r, uint64(c&0x0f), depth)
+	case c >= 0xa0 && c <= 0xbf:
+		data, err := readBinaryN(r, uint64(c&0x1f))
+		return string(data), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		if n, err = readUint(1 << (c - 0xc4)); err != nil {
+			return nil, err
+		}
+		return readBinaryN(r, n)
+
+	case 0xc7, 0xc8, 0xc9:
+		if n, err = readUint(1 << (c - 0xc7)); err != nil {
+			return nil, err
+		}
+		return readMsgpackExt(r, n)
+
+	case 0xca:
+		if n, err = readUint(4); err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		if n, err = readUint(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		if n, err = readUint(1 << (c - 0xcc)); err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+
+	case 0xd0:
+		n, err = readUint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err = readUint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err = readUint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err = readUint(8)
+		return int64(n), err
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(c-0xd4))
+
+	case 0xd9, 0xda, 0xdb:
+		if n, err = readUint(1 << (c - 0xd9)); err != nil {
+			return nil, err
+		}
+		data, err := readBinaryN(r, n)
+		return string(data), err
+
+	case 0xdc, 0xdd:
+		if n, err = readUint(2 << (c - 0xdc)); err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n, depth)
+
+	case 0xde, 0xdf:
+		if n, err = readUint(2 << (c - 0xde)); err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n, depth)
+	}
+
+	return nil, fmt.Errorf("invalid format 0x%02x", c)
+}
+
+func readMsgpackArray(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	result := make([]interface{}, 0, binaryCapacity(n))
+	for i := uint64(0); i < n; i++ {
+		v, err := readMsgpackValue(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	result := make(binaryMap, 0, binaryCapacity(n))
+	for i := uint64(0); i < n; i++ {
+		k, err := readMsgpackValue(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		v, err := readMsgpackValue(r, depth+1)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		result = append(result, binaryMapEntry{Key: k, Value: v})
+	}
+	return result, nil
+}
+
+// readMsgpackExt reads the extension type with the given data length.
+// The timestamp extension type -1 is decoded as time.Time, and the others are decoded as binary.
+func readMsgpackExt(r *bufio.Reader, n uint64) (interface{}, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	data, err := readBinaryN(r, n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != -1 {
+		return data, nil
+	}
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		x := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(x&0x3ffffffff), int64(x>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+	}
+	return nil, fmt.Errorf("invalid timestamp length %d", len(data))
+}
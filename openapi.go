@@ -0,0 +1,524 @@
+package rapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIDocument is the OpenAPI 3.1 document generated by Handler.OpenAPI.
+type OpenAPIDocument struct {
+	OpenAPI    string                      `json:"openapi"`
+	Info       OpenAPIInfo                 `json:"info"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents          `json:"components,omitempty"`
+}
+
+// OpenAPIInfo defines the metadata of the API in OpenAPIDocument.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIPathItem defines the operations of the single path by lower-case method names.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+// OpenAPIOperation defines the single operation of the path.
+type OpenAPIOperation struct {
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter defines the single path or query parameter of the operation.
+type OpenAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody defines the request body of the operation.
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse defines the single response of the operation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType defines the schema of the content by media type.
+type OpenAPIMediaType struct {
+	Schema *JSONSchema `json:"schema,omitempty"`
+}
+
+// OpenAPIComponents holds the reusable schemas of OpenAPIDocument.
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas,omitempty"`
+}
+
+// JSONSchema is the JSON Schema generated from Go types by using json struct tags.
+// The constraints are taken from "validate" struct tags.
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+}
+
+// OpenAPI generates the OpenAPI 3.1 document from the registered patterns and methods.
+// The inputs of GET and DELETE methods are documented as query parameters, and the inputs of POST, PUT and PATCH
+// methods are documented as request bodies. The outputs are documented if they are declared by WithOutput.
+func (h *Handler) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   make(map[string]*OpenAPIPathItem),
+	}
+	g := newJSONSchemaGenerator()
+
+	for _, ph := range h.getPatternHandlers() {
+		p, pathParams := openAPIPath(ph.pattern)
+		if p == "" {
+			continue
+		}
+		item := doc.Paths[p]
+		if item == nil {
+			item = &OpenAPIPathItem{}
+			doc.Paths[p] = item
+		}
+
+		methods, mhs := ph.getMethodHandlers()
+		registered := make(map[string]struct{}, len(methods))
+		for _, method := range methods {
+			registered[method] = struct{}{}
+		}
+		for i, method := range methods {
+			opMethods := []string{method}
+			if method == "" {
+				opMethods = nil
+				for _, m := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+					http.MethodDelete} {
+					if _, ok := registered[m]; !ok {
+						opMethods = append(opMethods, m)
+					}
+				}
+			}
+			for _, m := range opMethods {
+				(*item)[strings.ToLower(m)] = g.Operation(m, pathParams, mhs[i])
+			}
+		}
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components = &OpenAPIComponents{
+			Schemas: g.schemas,
+		}
+	}
+
+	return doc
+}
+
+// OpenAPIHandler returns an http.Handler that serves the OpenAPI document as JSON.
+func (h *Handler) OpenAPIHandler(info OpenAPIInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, "application/json", h.OpenAPI(info), http.StatusOK)
+	})
+}
+
+// openAPIPath converts the given pattern to the OpenAPI path, and returns the names of path parameters.
+func openAPIPath(pattern string) (p string, pathParams []string) {
+	idx := strings.Index(pattern, "/")
+	if idx < 0 {
+		return "", nil
+	}
+	p = pattern[idx:]
+	if !isPathTemplate(p) {
+		return p, nil
+	}
+	t, err := parsePathTemplate(p)
+	if err != nil {
+		return "", nil
+	}
+	parts := make([]string, 0, len(t.segments))
+	for _, seg := range t.segments {
+		if !seg.wildcard {
+			parts = append(parts, seg.literal)
+			continue
+		}
+		parts = append(parts, "{"+seg.name+"}")
+		pathParams = append(pathParams, seg.name)
+	}
+	return "/" + strings.Join(parts, "/"), pathParams
+}
+
+// jsonSchemaGenerator generates JSONSchema's, and keeps the named struct types as reusable schemas.
+type jsonSchemaGenerator struct {
+	schemas map[string]*JSONSchema
+	names   map[reflect.Type]string
+}
+
+func newJSONSchemaGenerator() *jsonSchemaGenerator {
+	return &jsonSchemaGenerator{
+		schemas: make(map[string]*JSONSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Operation generates the OpenAPIOperation of the given method and methodHandler.
+func (g *jsonSchemaGenerator) Operation(method string, pathParams []string, mh *methodHandler) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Responses: make(map[string]*OpenAPIResponse),
+	}
+
+	var inTyp reflect.Type
+	if mh.in != nil {
+		inTyp = indirectType(reflect.TypeOf(mh.in))
+	}
+
+	for _, name := range pathParams {
+		param := &OpenAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &JSONSchema{Type: "string"},
+		}
+		if inTyp != nil && inTyp.Kind() == reflect.Struct {
+			for i, j := 0, inTyp.NumField(); i < j; i++ {
+				field := inTyp.Field(i)
+				if field.IsExported() && !field.Anonymous && field.Tag.Get("path") == name {
+					param.Schema = g.Schema(field.Type)
+					break
+				}
+			}
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	switch method {
+	case http.MethodHead, http.MethodGet, http.MethodDelete:
+		if inTyp != nil && inTyp.Kind() == reflect.Struct {
+			for i, j := 0, inTyp.NumField(); i < j; i++ {
+				field := inTyp.Field(i)
+				if !field.IsExported() || field.Anonymous {
+					continue
+				}
+				if name := field.Tag.Get("path"); name != "" && name != "-" {
+					continue
+				}
+				fieldName, _ := parseJSONField(field)
+				if fieldName == "" {
+					continue
+				}
+				schema, required := g.FieldSchema(field)
+				op.Parameters = append(op.Parameters, &OpenAPIParameter{
+					Name:     fieldName,
+					In:       "query",
+					Required: required,
+					Schema:   schema,
+				})
+			}
+		}
+	default:
+		if inTyp != nil {
+			schema := g.Schema(inTyp)
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  make(map[string]*OpenAPIMediaType, len(mh.options.Codecs)),
+			}
+			if mh.options.StreamInput {
+				op.RequestBody.Content["application/json"] = &OpenAPIMediaType{Schema: &JSONSchema{Type: "array", Items: schema}}
+				op.RequestBody.Content[StreamNDJSON.MediaType()] = &OpenAPIMediaType{Schema: schema}
+			} else {
+				mediaTypes := codecMediaTypes(mh.options.Codecs)
+				if mh.options.FormBody {
+					mediaTypes = append(mediaTypes, "application/x-www-form-urlencoded", "multipart/form-data")
+				}
+				for _, mediaType := range mediaTypes {
+					op.RequestBody.Content[mediaType] = &OpenAPIMediaType{Schema: schema}
+				}
+			}
+		}
+	}
+
+	codes := make([]int, 0, len(mh.options.Outputs))
+	for code := range mh.options.Outputs {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		out := mh.options.Outputs[code]
+		key, description := "default", "Default response"
+		if code != 0 {
+			key, description = strconv.Itoa(code), http.StatusText(code)
+		}
+		resp := &OpenAPIResponse{
+			Description: description,
+		}
+		if out != nil && bodyAllowedForStatus(code) {
+			outTyp := reflect.TypeOf(out)
+			schema := g.Schema(outTyp)
+			mediaTypes := codecMediaTypes(mh.options.Codecs)
+			if t := indirectType(outTyp); t == reflect.TypeOf(ProblemDetails{}) || t == reflect.TypeOf(ProblemError{}) {
+				mediaTypes = []string{"application/problem+json"}
+			} else if t == reflect.TypeOf(RawContent{}) {
+				mediaTypes = []string{"application/octet-stream"}
+			}
+			resp.Content = make(map[string]*OpenAPIMediaType, len(mediaTypes))
+			for _, mediaType := range mediaTypes {
+				resp.Content[mediaType] = &OpenAPIMediaType{Schema: schema}
+			}
+		}
+		op.Responses[key] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &OpenAPIResponse{
+			Description: "Undeclared response",
+		}
+	}
+
+	return op
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schema generates the JSONSchema of the given type.
+func (g *jsonSchemaGenerator) Schema(typ reflect.Type) *JSONSchema {
+	typ = indirectType(typ)
+
+	switch typ {
+	case reflect.TypeOf(time.Time{}):
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return &JSONSchema{}
+	case reflect.TypeOf([]byte{}):
+		return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+	case formFileType, reflect.TypeOf(RawContent{}):
+		return &JSONSchema{Type: "string", Format: "binary"}
+	case reflect.TypeOf(ValidationError{}):
+		return &JSONSchema{Type: "array", Items: g.Schema(reflect.TypeOf(FieldError{}))}
+	case reflect.TypeOf(ProblemDetails{}), reflect.TypeOf(ProblemError{}):
+		return g.ref(reflect.TypeOf(ProblemDetails{}), func() *JSONSchema {
+			return &JSONSchema{
+				Type: "object",
+				Properties: map[string]*JSONSchema{
+					"type":     {Type: "string", Format: "uri-reference"},
+					"title":    {Type: "string"},
+					"status":   {Type: "integer"},
+					"detail":   {Type: "string"},
+					"instance": {Type: "string", Format: "uri-reference"},
+				},
+			}
+		})
+	}
+
+	if typ.Implements(jsonMarshalerType) || reflect.PtrTo(typ).Implements(jsonMarshalerType) {
+		return &JSONSchema{}
+	}
+	if typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return &JSONSchema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &JSONSchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &JSONSchema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		minimum := float64(0)
+		return &JSONSchema{Type: "integer", Minimum: &minimum}
+	case reflect.Float32:
+		return &JSONSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &JSONSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: g.Schema(typ.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.Schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.structSchema(typ)
+		}
+		return g.ref(typ, func() *JSONSchema {
+			return g.structSchema(typ)
+		})
+	}
+
+	return &JSONSchema{}
+}
+
+// FieldSchema generates the JSONSchema of the given struct field, and reports whether the field is required.
+func (g *jsonSchemaGenerator) FieldSchema(field reflect.StructField) (schema *JSONSchema, required bool) {
+	schema = g.Schema(field.Type)
+	tag, ok := field.Tag.Lookup("validate")
+	if !ok {
+		return schema, false
+	}
+	rules, err := parseValidationRules(tag)
+	if err != nil {
+		return schema, false
+	}
+	if schema.Ref != "" {
+		schema = &JSONSchema{Ref: schema.Ref}
+	}
+	return schema, applyValidationRulesToSchema(schema, rules)
+}
+
+// ref returns the reference to the reusable schema of the given named type, and generates it by f if not exists.
+func (g *jsonSchemaGenerator) ref(typ reflect.Type, f func() *JSONSchema) *JSONSchema {
+	name, ok := g.names[typ]
+	if !ok {
+		name = typ.Name()
+		for i := 2; ; i++ {
+			if _, exists := g.schemas[name]; !exists {
+				break
+			}
+			name = typ.Name() + strconv.Itoa(i)
+		}
+		g.names[typ] = name
+		g.schemas[name] = &JSONSchema{}
+		*g.schemas[name] = *f()
+	}
+	return &JSONSchema{Ref: "#/components/schemas/" + name}
+}
+
+// structSchema generates the object JSONSchema of the given struct type.
+func (g *jsonSchemaGenerator) structSchema(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{
+		Type:       "object",
+		Properties: make(map[string]*JSONSchema),
+	}
+	g.addStructProperties(schema, typ)
+	return schema
+}
+
+func (g *jsonSchemaGenerator) addStructProperties(schema *JSONSchema, typ reflect.Type) {
+	for i, j := 0, typ.NumField(); i < j; i++ {
+		field := typ.Field(i)
+		if field.Anonymous {
+			fieldTyp := indirectType(field.Type)
+			if name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]; name == "" && fieldTyp.Kind() == reflect.Struct {
+				g.addStructProperties(schema, fieldTyp)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		fieldName, _ := parseJSONField(field)
+		if fieldName == "" {
+			continue
+		}
+		if _, ok := schema.Properties[fieldName]; ok {
+			continue
+		}
+		fieldSchema, required := g.FieldSchema(field)
+		schema.Properties[fieldName] = fieldSchema
+		if required {
+			schema.Required = append(schema.Required, fieldName)
+		}
+	}
+}
+
+// applyValidationRulesToSchema applies the validation rules as constraints to the given schema,
+// and reports whether the rules contain "required".
+func applyValidationRulesToSchema(schema *JSONSchema, rules []validationRule) (required bool) {
+	for i, rule := range rules {
+		if schema.Ref != "" && rule.Name != "required" {
+			continue
+		}
+		switch rule.Name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			f, _ := strconv.ParseFloat(rule.Param, 64)
+			n := int(f)
+			switch schema.Type {
+			case "string":
+				if rule.Name != "max" {
+					schema.MinLength = &n
+				}
+				if rule.Name != "min" {
+					schema.MaxLength = &n
+				}
+			case "array":
+				if rule.Name != "max" {
+					schema.MinItems = &n
+				}
+				if rule.Name != "min" {
+					schema.MaxItems = &n
+				}
+			case "integer", "number":
+				if rule.Name == "min" {
+					schema.Minimum = &f
+				}
+				if rule.Name == "max" {
+					schema.Maximum = &f
+				}
+			}
+		case "pattern":
+			schema.Pattern = rule.Param
+		case "oneof":
+			for _, option := range strings.Fields(rule.Param) {
+				if schema.Type == "integer" || schema.Type == "number" {
+					if f, err := strconv.ParseFloat(option, 64); err == nil {
+						schema.Enum = append(schema.Enum, f)
+						continue
+					}
+				}
+				schema.Enum = append(schema.Enum, option)
+			}
+		case "email":
+			schema.Format = "email"
+		case "dive":
+			var items *JSONSchema
+			switch {
+			case schema.Items != nil:
+				items = schema.Items
+			case schema.AdditionalProperties != nil:
+				items = schema.AdditionalProperties
+			}
+			if items != nil && items.Ref == "" {
+				applyValidationRulesToSchema(items, rules[i+1:])
+			}
+			return required
+		}
+	}
+	return required
+}
+
+// indirectType returns the type that the given pointer type points to, repeatedly.
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -0,0 +1,169 @@
+package rapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// pathTemplate is the parsed form of templated patterns such as /users/{id}/orders/{orderID}.
+type pathTemplate struct {
+	pattern  string
+	segments []pathTemplateSegment
+	literals int
+	handler  http.Handler
+}
+
+// pathTemplateSegment defines single segment of pathTemplate.
+type pathTemplateSegment struct {
+	literal  string
+	name     string
+	wildcard bool
+	rest     bool
+}
+
+// isPathTemplate reports whether the given pattern contains path parameters.
+func isPathTemplate(pattern string) bool {
+	return strings.ContainsAny(pattern, "{}")
+}
+
+// parsePathTemplate parses the given templated pattern.
+func parsePathTemplate(pattern string) (t *pathTemplate, err error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("templated pattern %q must begin with slash", pattern)
+	}
+
+	t = &pathTemplate{
+		pattern: pattern,
+	}
+
+	names := make(map[string]struct{})
+	parts := strings.Split(pattern[1:], "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("invalid segment %q in templated pattern %q", part, pattern)
+			}
+			t.segments = append(t.segments, pathTemplateSegment{
+				literal: part,
+			})
+			t.literals++
+			continue
+		}
+
+		seg := pathTemplateSegment{
+			name:     part[1 : len(part)-1],
+			wildcard: true,
+		}
+		if strings.HasSuffix(seg.name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("rest segment %q must be at the end of templated pattern %q", part, pattern)
+			}
+			seg.name = strings.TrimSuffix(seg.name, "...")
+			seg.rest = true
+		}
+		if seg.name == "" || strings.ContainsAny(seg.name, "{}") {
+			return nil, fmt.Errorf("invalid segment %q in templated pattern %q", part, pattern)
+		}
+		if _, ok := names[seg.name]; ok {
+			return nil, fmt.Errorf("duplicate path parameter %q in templated pattern %q", seg.name, pattern)
+		}
+		names[seg.name] = struct{}{}
+		t.segments = append(t.segments, seg)
+	}
+
+	return t, nil
+}
+
+// Match matches the given url path and returns path values if matched.
+func (t *pathTemplate) Match(escapedPath string) (values map[string]string, ok bool) {
+	if !strings.HasPrefix(escapedPath, "/") {
+		return nil, false
+	}
+	parts := strings.Split(escapedPath[1:], "/")
+
+	values = make(map[string]string)
+	for i, seg := range t.segments {
+		if seg.rest {
+			value, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			if err != nil {
+				return nil, false
+			}
+			values[seg.name] = value
+			return values, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		value, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, false
+		}
+		if !seg.wildcard {
+			if value != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+		if value == "" {
+			return nil, false
+		}
+		values[seg.name] = value
+	}
+	if len(parts) != len(t.segments) {
+		return nil, false
+	}
+
+	return values, true
+}
+
+// pathTemplates is the ordered list of pathTemplate's. The more specific templates come first.
+type pathTemplates []*pathTemplate
+
+// Add adds the given pathTemplate by keeping the order.
+func (s *pathTemplates) Add(t *pathTemplate) error {
+	for _, t2 := range *s {
+		if t2.pattern == t.pattern {
+			return fmt.Errorf("multiple registrations for %s", t.pattern)
+		}
+	}
+	*s = append(*s, t)
+	sort.SliceStable(*s, func(i, j int) bool {
+		ti, tj := (*s)[i], (*s)[j]
+		if ti.literals != tj.literals {
+			return ti.literals > tj.literals
+		}
+		if len(ti.segments) != len(tj.segments) {
+			return len(ti.segments) > len(tj.segments)
+		}
+		return false
+	})
+	return nil
+}
+
+// Match returns the first pathTemplate which matches the given url path.
+func (s pathTemplates) Match(escapedPath string) (t *pathTemplate, values map[string]string) {
+	for _, t = range s {
+		var ok bool
+		if values, ok = t.Match(escapedPath); ok {
+			return t, values
+		}
+	}
+	return nil, nil
+}
+
+type pathValuesContextKey struct{}
+
+// contextWithPathValues returns a new context with the given path values.
+func contextWithPathValues(ctx context.Context, values map[string]string) context.Context {
+	return context.WithValue(ctx, pathValuesContextKey{}, values)
+}
+
+// pathValuesFromContext returns path values from the given context.
+func pathValuesFromContext(ctx context.Context) map[string]string {
+	values, _ := ctx.Value(pathValuesContextKey{}).(map[string]string)
+	return values
+}
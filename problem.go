@@ -0,0 +1,139 @@
+package rapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ProblemDetails defines the problem details for HTTP APIs described in RFC 7807.
+// It's sent as "application/problem+json" when it's given to SendFunc.
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// problemDetailsMembers is the list of standard members of ProblemDetails.
+var problemDetailsMembers = []string{"type", "title", "status", "detail", "instance"}
+
+// MarshalJSON is the implementation of json.Marshaler.
+// Extensions are encoded as top-level members.
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+len(problemDetailsMembers))
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for _, k := range problemDetailsMembers {
+		delete(m, k)
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON is the implementation of json.Unmarshaler.
+// Unknown top-level members are decoded into Extensions.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	result := ProblemDetails{}
+	fields := map[string]interface{}{
+		"type":     &result.Type,
+		"title":    &result.Title,
+		"status":   &result.Status,
+		"detail":   &result.Detail,
+		"instance": &result.Instance,
+	}
+	for k, v := range m {
+		if field, ok := fields[k]; ok {
+			if err := json.Unmarshal(v, field); err != nil {
+				return fmt.Errorf("unable to unmarshal member %q: %w", k, err)
+			}
+			continue
+		}
+		var ext interface{}
+		if err := json.Unmarshal(v, &ext); err != nil {
+			return fmt.Errorf("unable to unmarshal extension member %q: %w", k, err)
+		}
+		if result.Extensions == nil {
+			result.Extensions = make(map[string]interface{})
+		}
+		result.Extensions[k] = ext
+	}
+	*p = result
+	return nil
+}
+
+// problemCodec is the Codec of "application/problem+json" to send ProblemDetails.
+type problemCodec struct {
+	JSONCodec
+}
+
+// MediaType is the implementation of Codec.
+func (problemCodec) MediaType() string {
+	return "application/problem+json"
+}
+
+// ProblemError is the error created from the problem details returned from http server.
+// It is returned from Caller.Call when the response content type is "application/problem+json".
+type ProblemError struct {
+	ProblemDetails
+}
+
+// Error is the implementation of error.
+func (e *ProblemError) Error() string {
+	msg := e.Title
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Detail != "" {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += e.Detail
+	}
+	return fmt.Sprintf("problem error: %s", msg)
+}
+
+// ProblemErrorEncoder is an ErrorEncoder that writes the errors as problem details.
+// *ValidationError is written with the extension member "errors" which contains the list of field errors.
+func ProblemErrorEncoder(w http.ResponseWriter, r *http.Request, err error, code int) {
+	p := ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: err.Error(),
+	}
+	switch e := err.(type) {
+	case *ProblemError:
+		p = e.ProblemDetails
+	case *ValidationError:
+		p.Detail = "validation error"
+		p.Extensions = map[string]interface{}{
+			"errors": e,
+		}
+	}
+	if p.Status == 0 {
+		p.Status = code
+	}
+	writeJSON(w, "application/problem+json", p, code)
+}
@@ -0,0 +1,167 @@
+package rapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RawContent is the raw response body such as a file. It is sent when it's given to SendFunc as the output.
+// If the status code is 200, the content is served by http.ServeContent that handles Range, If-Range, HEAD and
+// the other conditional requests. Otherwise, the whole content is sent with the given status code.
+// RawContent should be declared as an output by WithOutput. Otherwise, the request is answered with 406 before
+// calling DoFunc if Accept header allows none of the codecs.
+type RawContent struct {
+	// Content is the content to send. If it's nil, Data is sent.
+	Content io.ReadSeeker
+
+	// Data is the content to send if Content is nil.
+	Data []byte
+
+	// ContentType is the media type of the content. If it's empty, the media type is detected from the extension of
+	// Filename or the content.
+	ContentType string
+
+	// Filename is sent in Content-Disposition header if it's not empty.
+	Filename string
+
+	// Inline sends Content-Disposition header as inline instead of attachment.
+	Inline bool
+
+	// ModTime is sent in Last-Modified header and used for conditional requests if it's not zero.
+	ModTime time.Time
+}
+
+// sendRaw sends the given RawContent as the response.
+func (h *methodHandler) sendRaw(w http.ResponseWriter, r *http.Request, raw *RawContent, code int, headers ...http.Header) {
+	content := raw.Content
+	if content == nil {
+		content = bytes.NewReader(raw.Data)
+	}
+
+	addResponseHeaders(w.Header(), headers...)
+	if raw.ContentType != "" {
+		w.Header().Set("Content-Type", raw.ContentType)
+	}
+	if raw.Filename != "" {
+		disposition := "attachment"
+		if raw.Inline {
+			disposition = "inline"
+		}
+		if v := mime.FormatMediaType(disposition, map[string]string{"filename": raw.Filename}); v != "" {
+			w.Header().Set("Content-Disposition", v)
+		}
+	}
+
+	// the content is read and written only on the handler goroutine, because it may be closed by the caller and
+	// the response can't be written after return.
+	if h.options.WriteTimeout > 0 {
+		deadline := time.Now().Add(h.options.WriteTimeout)
+		if setWriteDeadline(w, deadline) {
+			defer setWriteDeadline(w, time.Time{})
+		} else {
+			w = &timeoutResponseWriter{ResponseWriter: w, deadline: deadline}
+		}
+	}
+
+	if code == http.StatusOK {
+		http.ServeContent(w, r, raw.Filename, raw.ModTime, content)
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		ctype := mime.TypeByExtension(filepath.Ext(raw.Filename))
+		if ctype == "" {
+			var buf [512]byte
+			n, _ := io.ReadFull(content, buf[:])
+			ctype = http.DetectContentType(buf[:n])
+		}
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
+		panic(fmt.Errorf("unable to seek raw content: %w", err))
+	}
+
+	if !raw.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", raw.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := io.Copy(w, content); err != nil {
+		h.options.PerformError(fmt.Errorf("unable to write response body: %w", err), r)
+	}
+}
+
+// errWriteTimeout occurs when the response body can't be written in the write timeout.
+var errWriteTimeout = errors.New("write timeout")
+
+// timeoutResponseWriter is the http.ResponseWriter that fails writing the body after the deadline.
+// It's used if the write deadline can't be set, and it doesn't interrupt the write in progress.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	deadline time.Time
+}
+
+// Write is the implementation of io.Writer.
+func (w *timeoutResponseWriter) Write(p []byte) (n int, err error) {
+	if !time.Now().Before(w.deadline) {
+		return 0, errWriteTimeout
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// setWriteDeadline sets the write deadline of the given http.ResponseWriter or the one it wraps like
+// http.ResponseController. It returns false if the write deadline isn't supported.
+func setWriteDeadline(w http.ResponseWriter, deadline time.Time) bool {
+	for {
+		switch t := w.(type) {
+		case interface{ SetWriteDeadline(time.Time) error }:
+			return t.SetWriteDeadline(deadline) == nil
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// errResponseBodyTooLarge occurs when the response body exceeds the maximum response body size.
+var errResponseBodyTooLarge = errors.New("response body too large")
+
+// maxSizeReader is the reader that fails if the underlying reader exceeds the maximum size.
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read is the implementation of io.Reader.
+func (r *maxSizeReader) Read(p []byte) (n int, err error) {
+	if r.n < 0 {
+		return 0, errResponseBodyTooLarge
+	}
+	if int64(len(p)) > r.n+1 {
+		p = p[:r.n+1]
+	}
+	n, err = r.r.Read(p)
+	r.n -= int64(n)
+	if r.n < 0 {
+		return n + int(r.n), errResponseBodyTooLarge
+	}
+	return n, err
+}
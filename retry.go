@@ -0,0 +1,198 @@
+package rapi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy defines the policy of automatic retries in Caller.Call.
+// The zero values of the fields are replaced with their defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one. Default is 3.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Default is 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff limits the delay between attempts. Default is 10s.
+	// The call isn't retried if Retry-After header of the response requests a longer delay.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor to grow the delay after each retry. Default is 2.
+	Multiplier float64
+
+	// Jitter is the ratio between 0 and 1 to randomize the delay. Default is 0.2.
+	// A negative value disables jitter.
+	Jitter float64
+
+	// RetryableStatusCodes is the list of response status codes to retry.
+	// Default is 429, 502, 503 and 504.
+	RetryableStatusCodes []int
+
+	// RetryableRequestError reports whether the request should be retried after the given RequestError.
+	// By default, all RequestError's are retried except the ones caused by context cancellation.
+	RetryableRequestError func(err *RequestError) bool
+
+	// Methods is the list of methods to retry. Default is the idempotent methods: HEAD, GET, PUT and DELETE.
+	Methods []string
+}
+
+// do calls f with retries according to the policy.
+func (p *RetryPolicy) do(ctx context.Context, method string, f func() (*Response, error)) (result *Response, err error) {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	attempt := 1
+	for ; ; attempt++ {
+		result, err = f()
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= maxAttempts || !p.isRetryableMethod(method) {
+			break
+		}
+
+		retryable, retryAfter := p.isRetryable(err)
+		if !retryable {
+			break
+		}
+
+		if retryAfter > p.maxBackoff() {
+			break
+		}
+		delay := p.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			break
+		}
+
+		tmr := time.NewTimer(delay)
+		select {
+		case <-tmr.C:
+		case <-ctx.Done():
+			tmr.Stop()
+			return result, &RetryError{err, attempt}
+		}
+	}
+
+	return result, &RetryError{err, attempt}
+}
+
+// isRetryableMethod reports whether the given method is retryable.
+func (p *RetryPolicy) isRetryableMethod(method string) bool {
+	methods := p.Methods
+	if methods == nil {
+		methods = []string{http.MethodHead, http.MethodGet, http.MethodPut, http.MethodDelete}
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryable reports whether the given error is retryable, and returns the delay given by Retry-After header if any.
+func (p *RetryPolicy) isRetryable(err error) (retryable bool, retryAfter time.Duration) {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		if p.RetryableRequestError != nil {
+			return p.RetryableRequestError(requestErr), 0
+		}
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded), 0
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false, 0
+	}
+
+	statusCodes := p.RetryableStatusCodes
+	if statusCodes == nil {
+		statusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout}
+	}
+	for _, code := range statusCodes {
+		if statusErr.StatusCode() == code {
+			retryable = true
+			break
+		}
+	}
+	if !retryable {
+		return false, 0
+	}
+
+	switch statusErr.StatusCode() {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		retryAfter = parseRetryAfter(statusErr.Header().Get("Retry-After"))
+	}
+	return true, retryAfter
+}
+
+// backoff returns the exponential backoff delay with jitter after the given attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initialBackoff := p.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = 100 * time.Millisecond
+	}
+	maxBackoff := p.maxBackoff()
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	jitter := p.Jitter
+	if jitter == 0 {
+		jitter = 0.2
+	}
+	if jitter < 0 {
+		jitter = 0
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+
+	delay := float64(initialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+	delay *= 1 + jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// maxBackoff returns MaxBackoff or its default.
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return 10 * time.Second
+	}
+	return p.MaxBackoff
+}
+
+// parseRetryAfter parses the value of Retry-After header as delay seconds or HTTP date.
+func parseRetryAfter(retryAfter string) time.Duration {
+	retryAfter = strings.TrimSpace(retryAfter)
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
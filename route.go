@@ -0,0 +1,55 @@
+package rapi
+
+import (
+	"reflect"
+	"time"
+)
+
+// Route describes the single method registration of the pattern. It is returned from Handler.Routes.
+type Route struct {
+	Pattern            string
+	Method             string
+	In                 reflect.Type
+	Outputs            map[int]reflect.Type
+	MaxRequestBodySize int64
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	AllowEncoding      bool
+	Validation         bool
+	Recovery           bool
+	FormBody           bool
+	StreamInput        bool
+	Middlewares        int
+}
+
+// Routes returns the registered routes in the registration order with their effective options.
+// The method of the route is empty if it was registered for all methods.
+func (h *Handler) Routes() []Route {
+	var routes []Route
+	for _, ph := range h.getPatternHandlers() {
+		methods, mhs := ph.getMethodHandlers()
+		for i, method := range methods {
+			mh := mhs[i]
+			route := Route{
+				Pattern:            ph.pattern,
+				Method:             method,
+				In:                 reflect.TypeOf(mh.in),
+				Outputs:            make(map[int]reflect.Type, len(mh.options.Outputs)),
+				MaxRequestBodySize: mh.options.MaxRequestBodySize,
+				ReadTimeout:        mh.options.ReadTimeout,
+				WriteTimeout:       mh.options.WriteTimeout,
+				AllowEncoding:      mh.options.AllowEncoding,
+				Validation:         mh.options.Validation,
+				Recovery:           mh.options.Recovery,
+				FormBody:           mh.options.FormBody,
+				StreamInput:        mh.options.StreamInput,
+				Middlewares:        len(mh.options.Middlewares),
+			}
+			for code, out := range mh.options.Outputs {
+				route.Outputs[code] = reflect.TypeOf(out)
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
@@ -0,0 +1,891 @@
+package rapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamFormat is the format of the streaming response.
+type StreamFormat int
+
+const (
+	// StreamNDJSON streams items as newline delimited JSON with the media type "application/x-ndjson".
+	StreamNDJSON StreamFormat = iota
+
+	// StreamSSE streams items as Server-Sent Events with the media type "text/event-stream".
+	StreamSSE
+)
+
+// MediaType returns the media type of the stream format.
+func (f StreamFormat) MediaType() string {
+	switch f {
+	case StreamNDJSON:
+		return "application/x-ndjson"
+	case StreamSSE:
+		return "text/event-stream"
+	}
+	return ""
+}
+
+// Stream is the streaming response. It is sent when it's given to SendFunc as the output.
+// Func is called after the response header is written, and the response ends when Func returns.
+type Stream struct {
+	// Format is the format of the stream.
+	Format StreamFormat
+
+	// Heartbeat is the interval to send comments while idle to keep the connection alive.
+	// It's effective on StreamSSE.
+	Heartbeat time.Duration
+
+	// Func writes the items by using the given StreamWriter.
+	Func StreamFunc
+}
+
+// StreamFunc is a function type to write the items of Stream.
+type StreamFunc func(sw *StreamWriter) error
+
+// StreamEvent is the single event of StreamSSE. Data is encoded as JSON.
+type StreamEvent struct {
+	ID    string
+	Name  string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// errStreamWriteTimeout occurs when the stream item couldn't be written in the write timeout.
+var errStreamWriteTimeout = errors.New("stream write timeout")
+
+// StreamWriter writes the items of Stream or UploadStream. Each item of Stream is flushed to the client after
+// it's written, and the write timeout of Handler is applied to each item.
+type StreamWriter struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	format       StreamFormat
+	w            io.Writer
+	flushers     []func() error
+	writeTimeout time.Duration
+
+	mu  sync.Mutex
+	err error
+}
+
+// Context returns the context of the stream. It's done when the client disconnects or writing fails.
+func (sw *StreamWriter) Context() context.Context {
+	return sw.ctx
+}
+
+// Send writes the given item. The item is encoded as JSON.
+// It's written as a line on StreamNDJSON, and as the data of an unnamed event on StreamSSE.
+func (sw *StreamWriter) Send(item interface{}) error {
+	return sw.SendEvent(&StreamEvent{Data: item})
+}
+
+// SendEvent writes the given event. Only Data of the event is written as a line on StreamNDJSON.
+func (sw *StreamWriter) SendEvent(event *StreamEvent) error {
+	data, err := encodeStreamEvent(sw.format, event)
+	if err != nil {
+		return err
+	}
+	return sw.write(data)
+}
+
+// write writes the given data and flushes.
+func (sw *StreamWriter) write(data []byte) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.ctx.Err(); err != nil {
+		sw.err = err
+		return err
+	}
+
+	if sw.writeTimeout <= 0 {
+		if err := sw.writeFlush(data); err != nil {
+			sw.fail(err)
+			return err
+		}
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sw.writeFlush(data)
+	}()
+	timer := time.NewTimer(sw.writeTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-timer.C:
+		err = errStreamWriteTimeout
+	case <-sw.ctx.Done():
+		err = sw.ctx.Err()
+	}
+	if err != nil {
+		sw.fail(err)
+	}
+	return err
+}
+
+func (sw *StreamWriter) writeFlush(data []byte) error {
+	if _, err := sw.w.Write(data); err != nil {
+		return err
+	}
+	for _, flush := range sw.flushers {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fail marks the stream as failed. The mutex must be held.
+func (sw *StreamWriter) fail(err error) {
+	sw.err = err
+	sw.cancel()
+}
+
+// encodeStreamEvent encodes the given event in the given format.
+func encodeStreamEvent(format StreamFormat, event *StreamEvent) ([]byte, error) {
+	if event == nil {
+		return nil, errors.New("event is nil")
+	}
+
+	var data []byte
+	var err error
+	if format == StreamNDJSON || event.Data != nil {
+		data, err = json.Marshal(event.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode stream item: %w", err)
+		}
+	}
+
+	if format == StreamNDJSON {
+		return append(data, '\n'), nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if event.ID != "" {
+		if strings.ContainsAny(event.ID, "\r\n\x00") {
+			return nil, errors.New("invalid event id")
+		}
+		buf.WriteString("id: " + event.ID + "\n")
+	}
+	if event.Name != "" {
+		if strings.ContainsAny(event.Name, "\r\n") {
+			return nil, errors.New("invalid event name")
+		}
+		buf.WriteString("event: " + event.Name + "\n")
+	}
+	if event.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(event.Retry/time.Millisecond), 10) + "\n")
+	}
+	if data != nil {
+		for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// sendStream sends the given Stream as the response.
+func (h *methodHandler) sendStream(w http.ResponseWriter, r *http.Request, stream *Stream, code int, headers ...http.Header) {
+	mediaType := stream.Format.MediaType()
+	if mediaType == "" {
+		h.options.WriteError(w, r, newStatusTextError(http.StatusInternalServerError), http.StatusInternalServerError)
+		panic(fmt.Errorf("unknown stream format %d", stream.Format))
+	}
+
+	if accept := r.Header.Get("Accept"); strings.TrimSpace(accept) != "" && acceptQuality(parseAccept(accept), mediaType) <= 0 {
+		h.options.PerformError(fmt.Errorf("no acceptable media type for %q", accept), r)
+		h.options.WriteError(w, r, errors.New("not acceptable"), http.StatusNotAcceptable)
+		return
+	}
+
+	var compressor Compressor
+	if h.options.AllowEncoding {
+		compressor = negotiateCompressor(r.Header.Get("Accept-Encoding"), h.options.Compressors)
+	}
+
+	var wc io.WriteCloser
+	if compressor != nil {
+		var err error
+		wc, err = compressor.NewWriter(w, h.options.CompressionLevel)
+		if err != nil {
+			h.options.PerformError(fmt.Errorf("unable to create compressor %q: %w", compressor.Encoding(), err), r)
+			compressor, wc = nil, nil
+		}
+	}
+
+	addResponseHeaders(w.Header(), headers...)
+	addVaryHeader(w.Header(), "Accept")
+	if h.options.AllowEncoding {
+		addVaryHeader(w.Header(), "Accept-Encoding")
+	}
+	w.Header().Set("Content-Type", contentTypeOf(mediaType))
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if compressor != nil {
+		w.Header().Set("Content-Encoding", compressor.Encoding())
+	}
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sw := &StreamWriter{
+		ctx:          ctx,
+		cancel:       cancel,
+		format:       stream.Format,
+		w:            w,
+		writeTimeout: h.options.WriteTimeout,
+	}
+
+	if wc != nil {
+		sw.w = wc
+		if f, ok := wc.(interface{ Flush() error }); ok {
+			sw.flushers = append(sw.flushers, f.Flush)
+		}
+	}
+	if f, ok := w.(http.Flusher); ok {
+		sw.flushers = append(sw.flushers, func() error {
+			f.Flush()
+			return nil
+		})
+	}
+
+	// the header is flushed to let the client know the stream has started.
+	if err := sw.write(nil); err != nil {
+		h.options.PerformError(fmt.Errorf("unable to write stream: %w", err), r)
+		return
+	}
+
+	if stream.Format == StreamSSE && stream.Heartbeat > 0 {
+		go func() {
+			ticker := time.NewTicker(stream.Heartbeat)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if sw.write([]byte(":\n\n")) != nil {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if stream.Func != nil {
+		if err := stream.Func(sw); err != nil && !(errors.Is(err, context.Canceled) && r.Context().Err() != nil) {
+			h.options.PerformError(fmt.Errorf("stream error: %w", err), r)
+		}
+	}
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	cancel()
+	if sw.err != nil {
+		// the client disconnection isn't an error.
+		if !(errors.Is(sw.err, context.Canceled) && r.Context().Err() != nil) {
+			h.options.PerformError(fmt.Errorf("unable to write stream: %w", sw.err), r)
+		}
+		return
+	}
+	if wc != nil {
+		if err := wc.Close(); err != nil {
+			h.options.PerformError(fmt.Errorf("unable to write end of stream: %w", err), r)
+		}
+	}
+}
+
+// errStreamItemTooLarge occurs when the stream item exceeds the maximum response body size.
+var errStreamItemTooLarge = errors.New("stream item too large")
+
+// StreamReader reads the items of the NDJSON or Server-Sent Events response. It's returned from Caller.CallStream.
+// StreamReader isn't safe for concurrent use.
+type StreamReader struct {
+	ctx       context.Context
+	options   *callOptions
+	out       interface{}
+	result    *Response
+	format    StreamFormat
+	rd        *bufio.Reader
+	closers   []io.Closer
+	reconnect func(lastEventID string) (*Response, error)
+
+	event       *StreamEvent
+	lastEventID string
+	retry       time.Duration
+	reconnects  int
+	done        bool
+	err         error
+}
+
+// Response returns the current response of the stream. It changes after reconnection.
+func (s *StreamReader) Response() *Response {
+	return s.result
+}
+
+// Format returns the format of the stream.
+func (s *StreamReader) Format() StreamFormat {
+	return s.format
+}
+
+// Next reads the next item. It returns false when the stream ends or an error occurs.
+// On connection loss or end of Server-Sent Events, it reconnects by sending Last-Event-ID header until
+// the server answers 204 No Content or the reconnection attempts are exhausted.
+func (s *StreamReader) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+	s.event = nil
+
+	for {
+		var err error
+		if s.format == StreamSSE {
+			err = s.readEvent()
+		} else {
+			err = s.readItem()
+		}
+		if err == nil {
+			s.reconnects = 0
+			return true
+		}
+
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		var requestErr *RequestError
+		if s.format != StreamSSE || s.ctx.Err() != nil || (err != io.EOF && !errors.As(err, &requestErr)) {
+			if err == io.EOF {
+				s.done = true
+			} else {
+				s.err = err
+			}
+			_ = s.closeBody()
+			return false
+		}
+		if err = s.reconnectStream(err); err != nil {
+			if err == io.EOF {
+				s.done = true
+			} else {
+				s.err = err
+			}
+			return false
+		}
+		if s.done {
+			return false
+		}
+	}
+}
+
+// Item returns the current item that is decoded into the type of the Caller's output.
+func (s *StreamReader) Item() interface{} {
+	if s.event == nil {
+		return nil
+	}
+	return s.event.Data
+}
+
+// Event returns the current event. Data of the event is the current item.
+// On StreamNDJSON, only Data is set.
+func (s *StreamReader) Event() *StreamEvent {
+	return s.event
+}
+
+// Err returns the error that stopped the stream if any.
+func (s *StreamReader) Err() error {
+	return s.err
+}
+
+// Close closes the stream.
+func (s *StreamReader) Close() error {
+	s.done = true
+	return s.closeBody()
+}
+
+func (s *StreamReader) closeBody() (err error) {
+	for _, closer := range s.closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	s.closers = nil
+	return err
+}
+
+// reconnectStream reconnects to the stream after the given error. The reconnection attempts are counted until
+// the next item is read.
+func (s *StreamReader) reconnectStream(cause error) error {
+	_ = s.closeBody()
+	if s.reconnect == nil {
+		return cause
+	}
+
+	for s.reconnects < s.options.StreamReconnects {
+		s.reconnects++
+		delay := s.retry
+		if delay <= 0 {
+			delay = s.options.StreamReconnectDelay
+		}
+		tmr := time.NewTimer(delay)
+		select {
+		case <-tmr.C:
+		case <-s.ctx.Done():
+			tmr.Stop()
+			return s.ctx.Err()
+		}
+
+		result, err := s.reconnect(s.lastEventID)
+		if err == nil {
+			stream, ok := result.Out.(*StreamReader)
+			if !ok {
+				return errors.New("response output is not stream")
+			}
+			s.result = result
+			s.format = stream.format
+			s.rd = stream.rd
+			s.closers = stream.closers
+			s.done = stream.done
+			return nil
+		}
+		// the reconnection fails without retry unless the connection can't be established.
+		var requestErr *RequestError
+		if !errors.As(err, &requestErr) || s.ctx.Err() != nil {
+			return err
+		}
+		cause = err
+	}
+
+	return cause
+}
+
+// readLine reads the next line without the end of line. The line is limited by the maximum response body size.
+func (s *StreamReader) readLine() ([]byte, error) {
+	line, err := readStreamLine(s.rd, s.options.MaxResponseBodySize)
+	if err != nil && err != io.EOF && err != errStreamItemTooLarge {
+		return nil, &RequestError{err}
+	}
+	return line, err
+}
+
+// readItem reads the next line of StreamNDJSON. Blank lines are skipped.
+func (s *StreamReader) readItem() error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		item, err := s.decodeItem(line)
+		if err != nil {
+			return err
+		}
+		s.event = &StreamEvent{Data: item}
+		return nil
+	}
+}
+
+// readEvent reads the next event of StreamSSE as described in the HTML Living Standard.
+// The events without data are skipped, and the incomplete event at the end of stream is discarded.
+func (s *StreamReader) readEvent() error {
+	var data []byte
+	hasData := false
+	name := ""
+	var retry time.Duration
+
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			if !hasData {
+				name = ""
+				retry = 0
+				continue
+			}
+			item, err := s.decodeItem(data)
+			if err != nil {
+				return err
+			}
+			s.event = &StreamEvent{
+				ID:    s.lastEventID,
+				Name:  name,
+				Data:  item,
+				Retry: retry,
+			}
+			return nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := string(line), ""
+		if idx := bytes.IndexByte(line, ':'); idx >= 0 {
+			field, value = string(line[:idx]), strings.TrimPrefix(string(line[idx+1:]), " ")
+		}
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			if hasData {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			hasData = true
+			if max := s.options.MaxResponseBodySize; max > 0 && int64(len(data)) > max {
+				return errStreamItemTooLarge
+			}
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				s.retry = retry
+			}
+		}
+	}
+}
+
+// decodeItem decodes the given JSON data into a copy of the Caller's output.
+func (s *StreamReader) decodeItem(data []byte) (interface{}, error) {
+	outVal := reflect.ValueOf(s.out)
+	copiedOutVal, err := copyReflectValue(outVal)
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy output: %w", err)
+	}
+	err = json.Unmarshal(data, copiedOutVal.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode stream item: %w", err)
+	}
+	if outVal.Kind() == reflect.Ptr {
+		return copiedOutVal.Interface(), nil
+	}
+	return copiedOutVal.Elem().Interface(), nil
+}
+
+// readStreamLine reads the next line without the end of line from rd.
+// If max is greater than zero, the line is limited by max.
+func readStreamLine(rd *bufio.Reader, max int64) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := rd.ReadSlice('\n')
+		line = append(line, chunk...)
+		if max > 0 && int64(len(line)) > max+2 {
+			return nil, errStreamItemTooLarge
+		}
+		switch err {
+		case nil:
+			line = bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+		default:
+			return nil, err
+		}
+		if max > 0 && int64(len(line)) > max {
+			return nil, errStreamItemTooLarge
+		}
+		return line, nil
+	}
+}
+
+// errStreamReadTimeout occurs when the streaming request body couldn't be read in the read timeout.
+var errStreamReadTimeout = errors.New("stream read timeout")
+
+// InputStream iterates the items of the streaming request body. It's given as Request.In by WithStreamInput.
+// The items are decoded into the type of the input given to Register. InputStream isn't safe for concurrent use.
+type InputStream struct {
+	in         interface{}
+	validation bool
+	maxSize    int64
+	ndjson     bool
+	rd         *bufio.Reader
+	lr         *streamLimitReader
+	dec        *json.Decoder
+	started    bool
+
+	item  interface{}
+	index int
+	done  bool
+	err   error
+}
+
+// newInputStream creates a new InputStream that reads the items from r.
+func newInputStream(r io.Reader, ndjson bool, in interface{}, options *handlerOptions) *InputStream {
+	s := &InputStream{
+		in:         in,
+		validation: options.Validation,
+		maxSize:    options.MaxRequestBodySize,
+		ndjson:     ndjson,
+		index:      -1,
+	}
+	if ndjson {
+		s.rd = bufio.NewReader(r)
+	} else {
+		s.lr = &streamLimitReader{r: r}
+		s.dec = json.NewDecoder(s.lr)
+	}
+	return s
+}
+
+// Next reads the next item. It returns false when the stream ends or an error occurs.
+func (s *InputStream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+	s.item = nil
+
+	var data []byte
+	var err error
+	if s.ndjson {
+		for len(bytes.TrimSpace(data)) == 0 {
+			data, err = readStreamLine(s.rd, s.maxSize)
+			if err == io.EOF {
+				s.done = true
+				return false
+			}
+			if err != nil {
+				s.fail(err)
+				return false
+			}
+		}
+	} else {
+		if !s.started {
+			s.started = true
+			var tok json.Token
+			tok, err = s.dec.Token()
+			if err != nil {
+				s.fail(err)
+				return false
+			}
+			if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+				s.fail(errors.New("request body must be JSON array"))
+				return false
+			}
+		}
+		if !s.dec.More() {
+			if _, err = s.dec.Token(); err != nil {
+				s.fail(err)
+				return false
+			}
+			s.done = true
+			return false
+		}
+	}
+
+	outVal := reflect.ValueOf(s.in)
+	copiedOutVal, err := copyReflectValue(outVal)
+	if err != nil {
+		panic(fmt.Errorf("unable to copy input: %w", err))
+	}
+
+	if s.ndjson {
+		err = json.Unmarshal(data, copiedOutVal.Interface())
+	} else {
+		offset := s.dec.InputOffset()
+		if s.maxSize > 0 {
+			// the decoder may read ahead, so the limit is checked exactly after decoding.
+			s.lr.limit = offset + s.maxSize + 512
+		}
+		err = s.dec.Decode(copiedOutVal.Interface())
+		s.lr.limit = 0
+		if err == nil && s.maxSize > 0 && s.dec.InputOffset()-offset > s.maxSize {
+			err = errStreamItemTooLarge
+		}
+	}
+	if err != nil {
+		s.fail(err)
+		return false
+	}
+	s.index++
+
+	if s.validation && copiedOutVal.Elem().Kind() == reflect.Struct {
+		if err = validateStruct(copiedOutVal.Interface()); err != nil {
+			s.err = &StreamItemError{err, s.index}
+			return false
+		}
+	}
+
+	if outVal.Kind() == reflect.Ptr {
+		s.item = copiedOutVal.Interface()
+	} else {
+		s.item = copiedOutVal.Elem().Interface()
+	}
+	return true
+}
+
+// Item returns the current item.
+func (s *InputStream) Item() interface{} {
+	return s.item
+}
+
+// Index returns the zero-based index of the current item.
+func (s *InputStream) Index() int {
+	return s.index
+}
+
+// Err returns *StreamItemError if the stream stopped by an error.
+func (s *InputStream) Err() error {
+	return s.err
+}
+
+// fail stops the stream with the error of the next item.
+func (s *InputStream) fail(err error) {
+	s.err = &StreamItemError{err, s.index + 1}
+}
+
+// streamLimitReader is the reader that fails if it reads beyond the given limit.
+type streamLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+// Read is the implementation of io.Reader.
+func (r *streamLimitReader) Read(p []byte) (n int, err error) {
+	if r.limit > 0 {
+		if r.n >= r.limit {
+			return 0, errStreamItemTooLarge
+		}
+		if int64(len(p)) > r.limit-r.n {
+			p = p[:r.limit-r.n]
+		}
+	}
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// idleTimeoutReader is the reader that closes the request body if a read doesn't complete in the timeout.
+type idleTimeoutReader struct {
+	body     io.ReadCloser
+	timeout  time.Duration
+	timedOut int32
+}
+
+// Read is the implementation of io.Reader.
+func (r *idleTimeoutReader) Read(p []byte) (n int, err error) {
+	if r.timeout <= 0 {
+		return r.body.Read(p)
+	}
+	tmr := time.AfterFunc(r.timeout, func() {
+		atomic.StoreInt32(&r.timedOut, 1)
+		_ = r.body.Close()
+	})
+	n, err = r.body.Read(p)
+	tmr.Stop()
+	if atomic.LoadInt32(&r.timedOut) != 0 {
+		return 0, errStreamReadTimeout
+	}
+	return n, err
+}
+
+// UploadStream is the streaming request body. It's sent when it's given to Caller.Call as the input.
+// The request body is sent chunked, and the response is decoded as usual. The request isn't retried.
+type UploadStream struct {
+	// Reader is read as the raw request body. If it's nil, Func is called.
+	Reader io.Reader
+
+	// ContentType is the content type of the raw request body. The default is "application/octet-stream".
+	ContentType string
+
+	// Func writes the items as NDJSON by using the given StreamWriter.
+	Func StreamFunc
+}
+
+// newUploadBody returns the request body that streams the given UploadStream, and its content type.
+// The request body is compressed on the fly if compressor isn't nil.
+func newUploadBody(ctx context.Context, upload *UploadStream, compressor Compressor) (body io.ReadCloser, contentType string) {
+	contentType = StreamNDJSON.MediaType()
+	if upload.Reader != nil {
+		contentType = upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+	}
+
+	body = newPipeBody(compressor, func(w io.Writer) error {
+		if upload.Reader != nil {
+			_, err := io.Copy(w, upload.Reader)
+			return err
+		}
+		if upload.Func != nil {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			return upload.Func(&StreamWriter{
+				ctx:    ctx,
+				cancel: cancel,
+				format: StreamNDJSON,
+				w:      w,
+			})
+		}
+		return nil
+	})
+
+	return body, contentType
+}
+
+// newPipeBody returns the request body that is written by f in a new goroutine while it's read.
+// The body is compressed on the fly if compressor isn't nil, and the error of f is returned by reading the body.
+func newPipeBody(compressor Compressor, f func(w io.Writer) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		defer func() {
+			_ = pw.CloseWithError(err)
+		}()
+
+		var w io.Writer = pw
+		var wc io.WriteCloser
+		if compressor != nil {
+			wc, err = compressor.NewWriter(pw, -1)
+			if err != nil {
+				err = fmt.Errorf("unable to create compressor %q: %w", compressor.Encoding(), err)
+				return
+			}
+			w = wc
+		}
+
+		if err = f(w); err != nil {
+			return
+		}
+
+		if wc != nil {
+			err = wc.Close()
+		}
+	}()
+	return pr
+}
@@ -1,8 +1,6 @@
 package rapi
 
 import (
-	"compress/flate"
-	"compress/gzip"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -117,28 +115,81 @@ func valuesToStruct(values url.Values, target interface{}) (err error) {
 		}
 		value := values.Get(fieldName)
 
-		fieldVal := indirectVal.Field(i)
+		err = setFieldValue(indirectVal.Field(i), value)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal field %q value: %w", fieldName, err)
+		}
+	}
 
-		ifc, kind := fieldVal.Interface(), fieldVal.Kind()
+	return nil
+}
 
-		switch ifc.(type) {
-		case string, *string:
-			if kind != reflect.Ptr {
-				fieldVal.Set(reflect.ValueOf(value))
-			} else {
-				fieldVal.Set(reflect.ValueOf(&value))
-			}
-		case []byte, *[]byte, time.Time, *time.Time:
-			value = strconv.Quote(value)
-			err = json.Unmarshal([]byte(value), fieldVal.Addr().Interface())
-			if err != nil {
-				return fmt.Errorf("unable to unmarshal field %q value: %w", fieldName, err)
-			}
-		default:
-			err = json.Unmarshal([]byte(value), fieldVal.Addr().Interface())
-			if err != nil {
-				return fmt.Errorf("unable to unmarshal field %q value: %w", fieldName, err)
-			}
+// pathValuesToStruct puts path values to the given struct fields tagged with "path".
+// target must be non-nil struct pointer otherwise it panics.
+func pathValuesToStruct(values map[string]string, target interface{}) (err error) {
+	if target == nil {
+		panic(errors.New("target is nil"))
+	}
+
+	val := reflect.ValueOf(target)
+	typ := val.Type()
+
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		panic(errors.New("target must be struct pointer"))
+	}
+	if val.IsNil() {
+		panic(errors.New("target struct pointer is nil"))
+	}
+
+	indirectVal := val.Elem()
+	indirectValType := indirectVal.Type()
+
+	for i, j := 0, indirectValType.NumField(); i < j; i++ {
+		field := indirectValType.Field(i)
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("path")
+		if !ok || name == "" || name == "-" {
+			continue
+		}
+
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		err = setFieldValue(indirectVal.Field(i), value)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal path value %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setFieldValue sets the given string value to the struct field by converting it to the field type.
+func setFieldValue(fieldVal reflect.Value, value string) (err error) {
+	ifc, kind := fieldVal.Interface(), fieldVal.Kind()
+
+	switch ifc.(type) {
+	case string, *string:
+		if kind != reflect.Ptr {
+			fieldVal.Set(reflect.ValueOf(value))
+		} else {
+			fieldVal.Set(reflect.ValueOf(&value))
+		}
+	case []byte, *[]byte, time.Time, *time.Time:
+		value = strconv.Quote(value)
+		err = json.Unmarshal([]byte(value), fieldVal.Addr().Interface())
+		if err != nil {
+			return err
+		}
+	default:
+		err = json.Unmarshal([]byte(value), fieldVal.Addr().Interface())
+		if err != nil {
+			return err
 		}
 	}
 
@@ -267,51 +318,75 @@ func toJSONFieldName(s string) string {
 	return string(result)
 }
 
-// getContentEncoder returns the content encoder according to the given accept encoding for the given http.ResponseWriter.
-func getContentEncoder(w http.ResponseWriter, acceptEncoding string) (result io.WriteCloser, err error) {
-	for _, opt := range parseHTTPHeaderOptions(acceptEncoding) {
-		var q *float64
-		if s, ok := opt.Map["q"]; ok {
-			if f, e := strconv.ParseFloat(s, 64); e == nil {
-				q = &f
-			} else {
-				return nil, fmt.Errorf("quality level parse error: %w", e)
+// addResponseHeaders adds the given headers to dst except the headers managed by Handler such as Content-Type.
+func addResponseHeaders(dst http.Header, headers ...http.Header) {
+	for _, hdr := range headers {
+		for k, v := range hdr {
+			lk := strings.ToLower(k)
+			if lk == "accept" || strings.HasPrefix(lk, "accept-") {
+				continue
 			}
-		}
-
-		switch key := opt.KeyVals[0].Key; key {
-		case "gzip":
-			level := gzip.DefaultCompression
-			if q != nil {
-				newLevel := int(*q)
-				if gzip.NoCompression <= newLevel && newLevel <= gzip.BestCompression {
-					level = newLevel
-				} else {
-					return nil, fmt.Errorf("invalid quality level %d", newLevel)
-				}
+			if lk == "content" || strings.HasPrefix(lk, "content-") {
+				continue
 			}
-			w.Header().Set("Content-Encoding", key)
-			result, _ = gzip.NewWriterLevel(w, level)
-			return result, nil
-
-		case "deflate":
-			level := flate.DefaultCompression
-			if q != nil {
-				newLevel := int(*q)
-				if flate.NoCompression <= newLevel && newLevel <= flate.BestCompression {
-					level = newLevel
-				} else {
-					return nil, fmt.Errorf("invalid quality level %d", newLevel)
-				}
+			if lk == "transfer" || strings.HasPrefix(lk, "transfer-") {
+				continue
+			}
+			for _, v2 := range v {
+				dst.Add(k, v2)
 			}
-			w.Header().Set("Content-Encoding", key)
-			result, _ = flate.NewWriter(w, level)
-			return result, nil
-
 		}
 	}
+}
+
+// supportedMethods returns the methods supported by Handler except OPTIONS.
+func supportedMethods() []string {
+	return []string{http.MethodHead, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete}
+}
+
+// bodyAllowedForStatus reports whether the given response status code permits a body.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// responseWriter wraps http.ResponseWriter to track whether the response header was written.
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader is the implementation of http.ResponseWriter.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write is the implementation of http.ResponseWriter.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush is the implementation of http.Flusher.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
 
-	return nopCloserForWriter{w}, nil
+// Unwrap returns the underlying http.ResponseWriter.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 // nopCloserForWriter implements io.WriteCloser with a no-op Close method wrapping the provided io.Writer.
@@ -0,0 +1,447 @@
+package rapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError defines the single failed validation rule of the input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the implementation of error.
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %q %s", e.Field, e.Message)
+}
+
+// ValidationError occurs when the input doesn't satisfy the validation rules given by "validate" struct tags.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+// Error is the implementation of error.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return fmt.Sprintf("validation error: %s", strings.Join(msgs, "; "))
+}
+
+// MarshalJSON is the implementation of json.Marshaler. It encodes the list of FieldError's.
+func (e *ValidationError) MarshalJSON() ([]byte, error) {
+	fields := e.Fields
+	if fields == nil {
+		fields = []*FieldError{}
+	}
+	return json.Marshal(fields)
+}
+
+// validationRule defines single rule of "validate" struct tag.
+type validationRule struct {
+	Name   string
+	Param  string
+	Regexp *regexp.Regexp
+}
+
+var validationRulesCache sync.Map
+
+// parseValidationRules parses the given "validate" struct tag.
+// Rules are separated by comma, and comma can be escaped by backslash in rule parameters.
+func parseValidationRules(tag string) (rules []validationRule, err error) {
+	if v, ok := validationRulesCache.Load(tag); ok {
+		return v.([]validationRule), nil
+	}
+
+	var parts []string
+	var sb strings.Builder
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if c == '\\' && i+1 < len(tag) && tag[i+1] == ',' {
+			sb.WriteByte(',')
+			i++
+			continue
+		}
+		if c == ',' {
+			parts = append(parts, sb.String())
+			sb.Reset()
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	parts = append(parts, sb.String())
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		rule := validationRule{
+			Name: kv[0],
+		}
+		if len(kv) > 1 {
+			rule.Param = kv[1]
+		}
+		switch rule.Name {
+		case "required", "email", "dive":
+			if len(kv) > 1 {
+				return nil, fmt.Errorf("rule %q must not have parameter", rule.Name)
+			}
+		case "min", "max", "len":
+			if _, e := strconv.ParseFloat(rule.Param, 64); e != nil {
+				return nil, fmt.Errorf("rule %q has invalid parameter %q", rule.Name, rule.Param)
+			}
+		case "pattern":
+			rule.Regexp, err = regexp.Compile(rule.Param)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q has invalid parameter %q: %w", rule.Name, rule.Param, err)
+			}
+		case "oneof":
+			if strings.TrimSpace(rule.Param) == "" {
+				return nil, fmt.Errorf("rule %q must have parameter", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", rule.Name)
+		}
+		rules = append(rules, rule)
+	}
+
+	validationRulesCache.Store(tag, rules)
+	return rules, nil
+}
+
+// checkValidationRules checks all "validate" struct tags in the given type and its nested types.
+func checkValidationRules(typ reflect.Type) error {
+	return checkValidationRulesOfType(typ, make(map[reflect.Type]struct{}))
+}
+
+func checkValidationRulesOfType(typ reflect.Type, visited map[reflect.Type]struct{}) error {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) {
+		return nil
+	}
+	if _, ok := visited[typ]; ok {
+		return nil
+	}
+	visited[typ] = struct{}{}
+
+	for i, j := 0, typ.NumField(); i < j; i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			rules, err := parseValidationRules(tag)
+			if err == nil {
+				err = checkValidationRulesKind(field.Type, rules)
+			}
+			if err != nil {
+				return fmt.Errorf("field %s.%s: %w", typ.Name(), field.Name, err)
+			}
+		}
+		if err := checkValidationRulesOfType(field.Type, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkValidationRulesKind checks whether the given rules are applicable on the kind of the given type.
+// The rules on interfaces are checked while validating.
+func checkValidationRulesKind(typ reflect.Type, rules []validationRule) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Interface {
+		return nil
+	}
+
+	for i, rule := range rules {
+		if !isValidationRuleApplicable(rule.Name, typ.Kind()) {
+			return fmt.Errorf("rule %q is not applicable on %s", rule.Name, typ.Kind())
+		}
+		if rule.Name == "dive" {
+			return checkValidationRulesKind(typ.Elem(), rules[i+1:])
+		}
+	}
+
+	return nil
+}
+
+// isValidationRuleApplicable reports whether the rule of the given name is applicable on the given kind.
+func isValidationRuleApplicable(name string, kind reflect.Kind) bool {
+	isLen, isInt, isFloat := false, false, false
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		isLen = true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		isInt = true
+	case reflect.Float32, reflect.Float64:
+		isFloat = true
+	}
+
+	switch name {
+	case "required":
+		return true
+	case "min", "max":
+		return isLen || isInt || isFloat
+	case "len":
+		return isLen
+	case "pattern", "email":
+		return kind == reflect.String
+	case "oneof":
+		return kind == reflect.String || isInt
+	case "dive":
+		return kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+	}
+	return false
+}
+
+// validateStruct validates the given value according to "validate" struct tags.
+// It returns *ValidationError if any rule fails.
+func validateStruct(v interface{}) error {
+	var fields []*FieldError
+	err := validateValue(reflect.ValueOf(v), "", nil, &fields)
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func validateValue(val reflect.Value, name string, rules []validationRule, fields *[]*FieldError) (err error) {
+	if !val.IsValid() {
+		return nil
+	}
+
+	for i, rule := range rules {
+		if rule.Name == "dive" {
+			err = validateElems(val, name, rules[i+1:], fields)
+			if err != nil {
+				return err
+			}
+			rules = rules[:i]
+			break
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Name != "required" {
+			continue
+		}
+		if isEmptyValue(val) {
+			*fields = append(*fields, &FieldError{Field: name, Rule: rule.Name, Message: "is required"})
+			return nil
+		}
+	}
+
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	for _, rule := range rules {
+		var fe *FieldError
+		fe, err = applyValidationRule(val, rule)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		if fe != nil {
+			fe.Field = name
+			*fields = append(*fields, fe)
+		}
+	}
+
+	if val.Kind() == reflect.Struct && val.Type() != reflect.TypeOf(time.Time{}) {
+		typ := val.Type()
+		for i, j := 0, typ.NumField(); i < j; i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldName := name
+			if !field.Anonymous {
+				jsonName, _ := parseJSONField(field)
+				if jsonName == "" {
+					jsonName = field.Name
+				}
+				if fieldName != "" {
+					fieldName += "."
+				}
+				fieldName += jsonName
+			}
+			var fieldRules []validationRule
+			if tag, ok := field.Tag.Lookup("validate"); ok {
+				fieldRules, err = parseValidationRules(tag)
+				if err != nil {
+					return fmt.Errorf("field %q: %w", fieldName, err)
+				}
+			}
+			err = validateValue(val.Field(i), fieldName, fieldRules, fields)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateElems(val reflect.Value, name string, rules []validationRule, fields *[]*FieldError) (err error) {
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i, j := 0, val.Len(); i < j; i++ {
+			err = validateValue(val.Index(i), fmt.Sprintf("%s[%d]", name, i), rules, fields)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			err = validateValue(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key().Interface()), rules, fields)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("rule %q is not applicable on %s", "dive", val.Kind())
+	}
+
+	return nil
+}
+
+func applyValidationRule(val reflect.Value, rule validationRule) (fe *FieldError, err error) {
+	fail := func(format string, a ...interface{}) (*FieldError, error) {
+		return &FieldError{Rule: rule.Name, Message: fmt.Sprintf(format, a...)}, nil
+	}
+
+	switch rule.Name {
+	case "required":
+		return nil, nil
+
+	case "min", "max", "len":
+		param, _ := strconv.ParseFloat(rule.Param, 64)
+		var x float64
+		isLen := false
+		switch val.Kind() {
+		case reflect.String:
+			x, isLen = float64(utf8.RuneCountInString(val.String())), true
+		case reflect.Slice, reflect.Array, reflect.Map:
+			x, isLen = float64(val.Len()), true
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x = float64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			x = float64(val.Uint())
+		case reflect.Float32, reflect.Float64:
+			x = val.Float()
+		default:
+			return nil, fmt.Errorf("rule %q is not applicable on %s", rule.Name, val.Kind())
+		}
+		switch rule.Name {
+		case "min":
+			if x < param {
+				if isLen {
+					return fail("must have length at least %s", rule.Param)
+				}
+				return fail("must be at least %s", rule.Param)
+			}
+		case "max":
+			if x > param {
+				if isLen {
+					return fail("must have length at most %s", rule.Param)
+				}
+				return fail("must be at most %s", rule.Param)
+			}
+		case "len":
+			if !isLen {
+				return nil, fmt.Errorf("rule %q is not applicable on %s", rule.Name, val.Kind())
+			}
+			if x != param {
+				return fail("must have length %s", rule.Param)
+			}
+		}
+
+	case "pattern":
+		if val.Kind() != reflect.String {
+			return nil, fmt.Errorf("rule %q is not applicable on %s", rule.Name, val.Kind())
+		}
+		if !rule.Regexp.MatchString(val.String()) {
+			return fail("must match pattern %q", rule.Param)
+		}
+
+	case "oneof":
+		var s string
+		switch val.Kind() {
+		case reflect.String:
+			s = val.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(val.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			s = strconv.FormatUint(val.Uint(), 10)
+		default:
+			return nil, fmt.Errorf("rule %q is not applicable on %s", rule.Name, val.Kind())
+		}
+		options := strings.Fields(rule.Param)
+		for _, option := range options {
+			if s == option {
+				return nil, nil
+			}
+		}
+		return fail("must be one of [%s]", strings.Join(options, " "))
+
+	case "email":
+		if val.Kind() != reflect.String {
+			return nil, fmt.Errorf("rule %q is not applicable on %s", rule.Name, val.Kind())
+		}
+		s := val.String()
+		addr, e := mail.ParseAddress(s)
+		if e != nil || addr.Address != s {
+			return fail("must be a valid email address")
+		}
+
+	default:
+		return nil, errors.New("unknown rule")
+	}
+
+	return nil, nil
+}
+
+// isEmptyValue reports whether the given value is zero or has zero length.
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return val.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return val.IsNil()
+	}
+	return val.IsZero()
+}